@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// action is a binding type: what a key bound to it sends, and how to do the
+// same outside of Karabiner, e.g. from "karabingen run --type"
+type action interface {
+	// Generate returns the event sent by a key bound to the binding
+	Generate(config *Config, binding KeyBinding) (To, error)
+	// Execute does what the binding does, now
+	Execute(config *Config, binding KeyBinding) error
+}
+
+// actionFunc adapts a function generating the event of a binding to action.
+// Executing runs the generated shell command or opens the generated app.
+type actionFunc func(config *Config, binding KeyBinding) (To, error)
+
+func (f actionFunc) Generate(config *Config, binding KeyBinding) (To, error) {
+	return f(config, binding)
+}
+
+func (f actionFunc) Execute(config *Config, binding KeyBinding) error {
+	to, err := f(config, binding)
+	if err != nil {
+		return err
+	}
+	return executeTo(to)
+}
+
+// shellAction is an action running the shell command built from a binding
+func shellAction(build func(config *Config, binding KeyBinding) (string, error)) actionFunc {
+	return func(config *Config, binding KeyBinding) (To, error) {
+		command, err := build(config, binding)
+		if err != nil {
+			return To{}, err
+		}
+		return To{ShellCommand: command}, nil
+	}
+}
+
+// actions are the binding types, shared by option bindings, layers, leader
+// sequences, modifier keys and the bindings of custom rules
+var actions = map[string]action{
+	"app": actionFunc(func(config *Config, binding KeyBinding) (To, error) {
+		open := binding.Open
+		if open == "" {
+			open = config.AppOpen
+		}
+		return createAppOpenTo(open, binding.Val)
+	}),
+	"app_hide":   shellAction(appCommand),
+	"app_quit":   shellAction(appCommand),
+	"app_toggle": shellAction(appCommand),
+	"web": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return "open " + shellWord(binding.Val), nil
+	}),
+	"shell": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return expandSecrets(binding.Val)
+	}),
+	"key": actionFunc(createKeyTo),
+	"consumer": actionFunc(func(config *Config, binding KeyBinding) (To, error) {
+		if err := validateKeyCode("consumer_key_code", binding.Val); err != nil {
+			return To{}, err
+		}
+		repeat := true
+		return To{
+			ConsumerKeyCode: binding.Val,
+			Modifiers:       binding.Modifiers,
+			Repeat:          &repeat,
+		}, nil
+	}),
+	"symbol": actionFunc(func(config *Config, binding KeyBinding) (To, error) {
+		to, ok := symbolKeys[binding.Val]
+		if !ok {
+			return To{}, fmt.Errorf("unknown symbol %q", binding.Val)
+		}
+		return to, nil
+	}),
+	"char": actionFunc(func(config *Config, binding KeyBinding) (To, error) {
+		return createCharTo(binding.Val)
+	}),
+	"hammerspoon": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return fmt.Sprintf("%s -c %s", shellWord(config.toolPath("hs")), shellQuote(binding.Val)), nil
+	}),
+	"uri": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return fmt.Sprintf("open %s", shellQuote(binding.Val)), nil
+	}),
+	"obsidian": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		uri, err := createObsidianURI(config.Obsidian, binding.Val)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("open %s", shellQuote(uri)), nil
+	}),
+	"vscode": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return editorCommand(config, "code", binding.Val)
+	}),
+	"editor": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return editorCommand(config, config.ProjectEditor, binding.Val)
+	}),
+	"folder": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		dir, err := expandDir(binding.Val)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("open %s", shellQuote(dir)), nil
+	}),
+	"settings": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return createSettingsCommand(binding.Val)
+	}),
+	"http": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		return createHTTPCommand(binding)
+	}),
+	"alias": aliasAction{},
+	"km": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		script := fmt.Sprintf(`tell application "Keyboard Maestro Engine" to do script %s`, appleScriptString(binding.Val))
+		return osascriptCommand(script), nil
+	}),
+	"btt": shellAction(func(config *Config, binding KeyBinding) (string, error) {
+		triggerName := strings.ReplaceAll(url.QueryEscape(binding.Val), "+", "%20")
+		return "open -g " + shellQuote("btt://trigger_named/?trigger_name="+triggerName), nil
+	}),
+	"cursor": actionFunc(func(config *Config, binding KeyBinding) (To, error) {
+		// Center of the given display, numbered from 1
+		display, err := strconv.Atoi(binding.Val)
+		if err != nil || display < 1 {
+			return To{}, fmt.Errorf("invalid display number %q", binding.Val)
+		}
+		return To{
+			SoftwareFunction: &SoftwareFunction{
+				SetMouseCursorPosition: &SetMouseCursorPosition{
+					X:      "50%",
+					Y:      "50%",
+					Screen: display - 1,
+				},
+			},
+		}, nil
+	}),
+}
+
+func lookupAction(name string) (action, error) {
+	a, ok := actions[name]
+	if !ok {
+		names := make([]string, 0, len(actions))
+		for name := range actions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown binding type %q (supported: %s)", name, strings.Join(names, ", "))
+	}
+	return a, nil
+}
+
+func appCommand(config *Config, binding KeyBinding) (string, error) {
+	return createAppCommand(binding.Type, binding.Val)
+}
+
+func editorCommand(config *Config, editor, val string) (string, error) {
+	dir, err := expandDir(val)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s", shellWord(config.toolPath(editor)), shellQuote(dir)), nil
+}
+
+func createKeyTo(config *Config, binding KeyBinding) (To, error) {
+	key, modifiers := binding.Val, []string(binding.Modifiers)
+	// A chord like "cmd+shift+4" carries its own modifiers
+	if len(key) > 1 && strings.Contains(key, "+") {
+		var chordModifiers []string
+		var err error
+		key, chordModifiers, err = parseKeyChord(key)
+		if err != nil {
+			return To{}, err
+		}
+		modifiers = append(chordModifiers, modifiers...)
+	}
+	if err := validateKeyCode("key_code", key); err != nil {
+		return To{}, err
+	}
+	return To{
+		KeyCode:   key,
+		Modifiers: modifiers,
+	}, nil
+}
+
+// aliasAction binds a command of the aliases section, read when the key is
+// pressed: the generated binding calls "karabingen run", which executes it
+type aliasAction struct{}
+
+func (aliasAction) Generate(config *Config, binding KeyBinding) (To, error) {
+	if _, ok := config.Aliases[binding.Val]; !ok {
+		return To{}, fmt.Errorf("unknown alias %q", binding.Val)
+	}
+	executable, err := karabingenExecutable()
+	if err != nil {
+		return To{}, err
+	}
+	return To{
+		ShellCommand: fmt.Sprintf("%s run --config %s %s", shellQuote(executable), shellQuote(config.path), shellQuote(binding.Val)),
+	}, nil
+}
+
+func (aliasAction) Execute(config *Config, binding KeyBinding) error {
+	command, ok := config.Aliases[binding.Val]
+	if !ok {
+		return fmt.Errorf("alias %q is not defined in %s", binding.Val, config.path)
+	}
+	if err := runShellCommand(command); err != nil {
+		return fmt.Errorf("alias %q failed: %w", binding.Val, err)
+	}
+	return nil
+}
+
+// executeBinding does what a binding does, the way Karabiner would when its key
+// is pressed, for the bindings not sending key events
+func executeBinding(config *Config, binding KeyBinding) error {
+	if len(binding.Actions) > 0 {
+		for i, a := range binding.Actions {
+			if a.Type == "" {
+				a.Type = binding.Type
+			}
+			if err := executeBinding(config, a); err != nil {
+				return fmt.Errorf("action %d: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+	if name, ok := strings.CutPrefix(binding.Val, "sys:"); ok {
+		to, err := createSystemActionTo(name)
+		if err != nil {
+			return err
+		}
+		return executeTo(to)
+	}
+	a, err := lookupAction(binding.Type)
+	if err != nil {
+		return err
+	}
+	return a.Execute(config, binding)
+}
+
+// executeTo runs the shell command of an event or opens its application
+func executeTo(to To) error {
+	switch {
+	case to.ShellCommand != "":
+		return runShellCommand(to.ShellCommand)
+	case to.SoftwareFunction != nil && to.SoftwareFunction.OpenApplication != nil:
+		return runShellCommand("open -a " + shellQuote(to.SoftwareFunction.OpenApplication.FilePath))
+	}
+	return fmt.Errorf("the binding sends key events, which only Karabiner can send")
+}
+
+func runShellCommand(command string) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var frontmostDelay time.Duration
+
+var appsCmd = &cobra.Command{
+	Use:   "apps",
+	Short: "Application helpers",
+	Long:  `Commands for looking up applications while writing the config.`,
+}
+
+var frontmostAppsCmd = &cobra.Command{
+	Use:   "frontmost",
+	Short: "Print the frontmost application's bundle identifier",
+	Long: `Print the bundle identifier of the frontmost application, followed by its
+name and window title, e.g. for frontmost_application conditions. Since the
+terminal running the command is frontmost, use --delay to switch apps first:
+
+  karabingen apps frontmost --delay 3s`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		time.Sleep(frontmostDelay)
+		return printFrontmostApp()
+	},
+}
+
+func init() {
+	frontmostAppsCmd.Flags().DurationVar(&frontmostDelay, "delay", 0, "Wait before reading the frontmost app, e.g. 3s")
+}
+
+// AppleScript returning the bundle identifier, name and front window title of the frontmost app
+const frontmostAppScript = `
+tell application "System Events"
+	set p to first process whose frontmost is true
+	set windowTitle to ""
+	try
+		set windowTitle to name of front window of p
+	end try
+	return (bundle identifier of p) & linefeed & (name of p) & linefeed & windowTitle
+end tell
+`
+
+func printFrontmostApp() error {
+	output, err := exec.Command("osascript", "-e", frontmostAppScript).Output()
+	if err != nil {
+		return fmt.Errorf("failed to get frontmost app: %w", err)
+	}
+
+	lines := strings.SplitN(strings.TrimRight(string(output), "\n"), "\n", 3)
+	for len(lines) < 3 {
+		lines = append(lines, "")
+	}
+	fmt.Println(lines[0])
+	fmt.Printf("name:   %s\n", lines[1])
+	fmt.Printf("window: %s\n", lines[2])
+	return nil
+}
+
+// appName turns an app binding value into an AppleScript application name,
+// accepting both "Safari" and "/Applications/Safari.app"
+func appName(val string) string {
+	return strings.TrimSuffix(filepath.Base(val), ".app")
+}
+
+// createAppOpenTo opens the app of an "app" binding: "launch" focuses it,
+// launching it if needed, "focus" only focuses a running app and "new" always
+// launches a new instance
+func createAppOpenTo(open, val string) (To, error) {
+	switch open {
+	case "launch":
+		return To{
+			SoftwareFunction: &SoftwareFunction{
+				OpenApplication: &OpenApplication{
+					FilePath: val,
+				},
+			},
+		}, nil
+	case "focus":
+		app := appleScriptString(appName(val))
+		return To{
+			ShellCommand: osascriptCommand(
+				fmt.Sprintf("if application %s is running then", app),
+				fmt.Sprintf("tell application %s to activate", app),
+				"end if",
+			),
+		}, nil
+	case "new":
+		return To{
+			ShellCommand: "open -n -a " + shellQuote(val),
+		}, nil
+	}
+	return To{}, fmt.Errorf("unsupported app open %q (supported: launch, focus, new)", open)
+}
+
+// createAppCommand builds the osascript command of an app_hide, app_quit or
+// app_toggle binding. Processes are looked up by bundle identifier, since the
+// process name can differ from the app name (e.g. "Code" for Visual Studio Code).
+func createAppCommand(verb, val string) (string, error) {
+	if val == "" {
+		return "", fmt.Errorf("%s binding needs an app", verb)
+	}
+	app := appleScriptString(appName(val))
+	hide := fmt.Sprintf(`tell application "System Events" to set visible of (first process whose bundle identifier is (id of application %s)) to false`, app)
+
+	switch verb {
+	case "app_hide":
+		return osascriptCommand(
+			fmt.Sprintf("if application %s is running then", app),
+			hide,
+			"end if",
+		), nil
+	case "app_quit":
+		return osascriptCommand(
+			fmt.Sprintf("if application %s is running then", app),
+			fmt.Sprintf("tell application %s to quit", app),
+			"end if",
+		), nil
+	case "app_toggle":
+		// Hide when focused, otherwise focus or launch it
+		return osascriptCommand(
+			fmt.Sprintf("if application %s is running and frontmost of application %s then", app, app),
+			hide,
+			"else",
+			fmt.Sprintf("tell application %s to activate", app),
+			"end if",
+		), nil
+	}
+	return "", fmt.Errorf("unknown app action %q", verb)
+}
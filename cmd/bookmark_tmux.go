@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -11,6 +14,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	bookmarkGroup        string
+	bookmarkKeyFromStdin bool
+	bookmarkPrintEntry   bool
+	bookmarkNameTemplate string
+	bookmarkConfigPath   string
+)
+
 var bookmarkTmuxCmd = &cobra.Command{
 	Use:   "bookmark [jumplist_file]",
 	Short: "Add current directory to tmux jump list",
@@ -20,8 +31,18 @@ If no jumplist file is specified, defaults to ~/.tmuxjumplist.
 The bookmark format is: key:name:directory
 Where:
   - key: A single character (0-9, a-z, A-Z) to trigger the session
-  - name: The session name (defaults to directory basename)
-  - directory: The full path to the directory`,
+  - name: The session name, from the tmux_jump.session_name template
+    (default "{base}", the directory basename)
+  - directory: The full path to the directory
+
+Lines starting with "## " are group headers; --group adds the bookmark at the
+end of that group. Comments and blank lines are kept when the file is rewritten.
+
+For scripts, --key-from-stdin reads the key (and, if the key is taken, the
+y/n/a answer on the next line) from stdin without prompting, and --print-entry
+prints only the resulting entry:
+
+  echo p | karabingen tmux bookmark --key-from-stdin --print-entry`,
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -46,19 +67,46 @@ Where:
 			bookmarkFile = filepath.Join(home, bookmarkFile[2:])
 		}
 
-		return addBookmark(bookmarkFile)
+		nameTemplate := bookmarkNameTemplate
+		if !cmd.Flags().Changed("name-template") {
+			template, err := loadSessionNameTemplate(bookmarkConfigPath)
+			if err != nil {
+				return err
+			}
+			nameTemplate = template
+		}
+
+		return addBookmark(bookmarkFile, nameTemplate, bookmarkGroup, bookmarkKeyFromStdin, bookmarkPrintEntry)
 	},
 }
 
-func addBookmark(bookmarkFile string) error {
+func init() {
+	bookmarkTmuxCmd.Flags().StringVarP(&bookmarkGroup, "group", "g", "", "Add the bookmark to this group (a \"## <group>\" header line)")
+	bookmarkTmuxCmd.Flags().BoolVar(&bookmarkKeyFromStdin, "key-from-stdin", false, "Read the key from stdin without prompting")
+	bookmarkTmuxCmd.Flags().BoolVar(&bookmarkPrintEntry, "print-entry", false, "Print only the added entry to stdout")
+	bookmarkTmuxCmd.Flags().StringVar(&bookmarkNameTemplate, "name-template", "", "Session name template ({base}, {parent}, {repo}), overrides tmux_jump.session_name")
+	bookmarkTmuxCmd.Flags().StringVar(&bookmarkConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+}
+
+func addBookmark(bookmarkFile, nameTemplate, group string, keyFromStdin, printEntry bool) error {
+	// Prompts go to stderr when stdout is captured, and nowhere without a terminal
+	var prompt io.Writer = os.Stdout
+	if keyFromStdin {
+		prompt = io.Discard
+	} else if printEntry {
+		prompt = os.Stderr
+	}
+
 	// Get current directory
 	pwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get current directory: %w", err)
 	}
 
-	// Get directory name
-	name := filepath.Base(pwd)
+	name, err := sessionName(nameTemplate, pwd)
+	if err != nil {
+		return err
+	}
 
 	// Read existing bookmarks to find used keys
 	usedKeys, err := getUsedKeys(bookmarkFile)
@@ -68,63 +116,117 @@ func addBookmark(bookmarkFile string) error {
 
 	// Display used keys if any
 	if len(usedKeys) > 0 {
-		fmt.Printf("Already used keys: %s\n", strings.Join(usedKeys, " "))
+		fmt.Fprintf(prompt, "Already used keys: %s\n", strings.Join(usedKeys, " "))
 	}
 
 	// Prompt for key
-	fmt.Printf("Enter key for '%s': ", name)
+	fmt.Fprintf(prompt, "Enter key for '%s': ", name)
 	reader := bufio.NewReader(os.Stdin)
 	key, err := reader.ReadString('\n')
-	if err != nil {
+	if err != nil && !(keyFromStdin && err == io.EOF) {
 		return fmt.Errorf("failed to read input: %w", err)
 	}
 	key = strings.TrimSpace(key)
 
 	if key == "" {
-		fmt.Println("No key provided, aborting.")
+		if keyFromStdin {
+			return fmt.Errorf("no key provided on stdin")
+		}
+		fmt.Fprintln(prompt, "No key provided, aborting.")
 		return nil
 	}
 
+	lines, err := readJumplistLines(bookmarkFile)
+	if err != nil {
+		return fmt.Errorf("failed to read bookmark file: %w", err)
+	}
+	entry := fmt.Sprintf("%s:%s:%s", key, name, pwd)
+	added := entry
+
 	// Check if key already exists
 	if keyExists(usedKeys, key) {
-		fmt.Printf("Warning: key '%s' already exists in %s\n", key, bookmarkFile)
-		fmt.Print("Overwrite? (y/n/a - y:replace, n:cancel, a:append): ")
+		fmt.Fprintf(prompt, "Warning: key '%s' already exists in %s\n", key, bookmarkFile)
+		fmt.Fprint(prompt, "Overwrite? (y/n/a - y:replace, n:cancel, a:append): ")
 		confirm, err := reader.ReadString('\n')
-		if err != nil {
+		if err != nil && !(keyFromStdin && err == io.EOF) {
 			return fmt.Errorf("failed to read confirmation: %w", err)
 		}
 		confirm = strings.TrimSpace(strings.ToLower(confirm))
 
 		switch confirm {
 		case "y":
-			// Remove existing entry with this key
-			if err := removeKeyFromFile(bookmarkFile, key); err != nil {
-				return fmt.Errorf("failed to remove existing key: %w", err)
+			// Replace the existing entry in place, unless it moves to another group
+			if group == "" {
+				lines = replaceJumplistEntry(lines, key, entry)
+				entry = ""
+			} else {
+				lines = replaceJumplistEntry(lines, key, "")
 			}
 		case "a":
 			// Just append, don't remove existing
 		default:
-			fmt.Println("Cancelled.")
+			if keyFromStdin {
+				return fmt.Errorf("key '%s' already exists in %s", key, bookmarkFile)
+			}
+			fmt.Fprintln(prompt, "Cancelled.")
 			return nil
 		}
 	}
 
-	// Append new bookmark
-	entry := fmt.Sprintf("%s:%s:%s\n", key, name, pwd)
-	f, err := os.OpenFile(bookmarkFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-	if err != nil {
-		return fmt.Errorf("failed to open bookmark file: %w", err)
+	if entry != "" {
+		lines = insertJumplistEntry(lines, entry, group)
 	}
-	defer f.Close()
-
-	if _, err := f.WriteString(entry); err != nil {
+	if err := os.WriteFile(bookmarkFile, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
 		return fmt.Errorf("failed to write bookmark: %w", err)
 	}
 
-	fmt.Printf("Added: %s:%s:%s\n", key, name, pwd)
+	if printEntry {
+		fmt.Println(added)
+	} else {
+		fmt.Fprintf(prompt, "Added: %s\n", added)
+	}
 	return nil
 }
 
+// loadSessionNameTemplate reads tmux_jump.session_name, falling back to the
+// directory basename without a config
+func loadSessionNameTemplate(configPath string) (string, error) {
+	config, err := loadDefaultConfig(configPath)
+	if errors.Is(err, errNoConfig) {
+		return "{base}", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return config.TmuxJump.SessionName, nil
+}
+
+// sessionName derives a session name for dir from a template. {base} is the
+// directory name, {parent} the name of its parent and {repo} the name of the
+// enclosing git repository (the directory name outside of a repository).
+func sessionName(template, dir string) (string, error) {
+	repo := filepath.Base(dir)
+	if out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output(); err == nil {
+		repo = filepath.Base(strings.TrimSpace(string(out)))
+	}
+
+	name := strings.NewReplacer(
+		"{base}", filepath.Base(dir),
+		"{parent}", filepath.Base(filepath.Dir(dir)),
+		"{repo}", repo,
+	).Replace(template)
+	if strings.ContainsAny(name, "{}") {
+		return "", fmt.Errorf("invalid session name template %q (placeholders: {base}, {parent}, {repo})", template)
+	}
+
+	// ":" separates jumplist fields and tmux replaces "." in session names
+	name = strings.NewReplacer(":", "_", ".", "_").Replace(name)
+	if name == "" {
+		return "", fmt.Errorf("session name template %q gives an empty name", template)
+	}
+	return name, nil
+}
+
 func getUsedKeys(bookmarkFile string) ([]string, error) {
 	file, err := os.Open(bookmarkFile)
 	if err != nil {
@@ -165,30 +267,70 @@ func keyExists(keys []string, key string) bool {
 	return false
 }
 
-func removeKeyFromFile(bookmarkFile, keyToRemove string) error {
-	// Read all lines
-	file, err := os.Open(bookmarkFile)
+// readJumplistLines returns the raw lines of a jumplist, including comments and
+// blank lines, so that rewrites keep the file's structure
+func readJumplistLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	keyRegex := regexp.MustCompile(`^` + regexp.QuoteMeta(keyToRemove) + `:`)
+	content := strings.TrimRight(string(data), "\n")
+	if content == "" {
+		return nil, nil
+	}
+	return strings.Split(content, "\n"), nil
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		// Skip lines that start with the key to remove
+// replaceJumplistEntry replaces the entries bound to key with entry, keeping
+// the position of the first one; an empty entry removes them
+func replaceJumplistEntry(lines []string, key, entry string) []string {
+	keyRegex := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s*:`)
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
 		if !keyRegex.MatchString(line) {
-			lines = append(lines, line)
+			result = append(result, line)
+			continue
+		}
+		if entry != "" {
+			result = append(result, entry)
+			entry = ""
 		}
 	}
+	return result
+}
 
-	if err := scanner.Err(); err != nil {
-		return err
+// insertJumplistEntry adds entry at the end of the given group, creating the
+// group header at the end of the file if needed. Without a group the entry is
+// appended to the file.
+func insertJumplistEntry(lines []string, entry, group string) []string {
+	if group == "" {
+		return append(lines, entry)
 	}
 
-	// Write back to file
-	return os.WriteFile(bookmarkFile, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+	header := -1
+	for i, line := range lines {
+		if name, ok := jumplistGroup(line); ok && name == group {
+			header = i
+			break
+		}
+	}
+	if header == -1 {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		return append(lines, jumplistGroupPrefix+group, entry)
+	}
+
+	// The group ends at the next group header or blank line
+	end := header + 1
+	for end < len(lines) {
+		if _, ok := jumplistGroup(lines[end]); ok || strings.TrimSpace(lines[end]) == "" {
+			break
+		}
+		end++
+	}
+	return append(lines[:end], append([]string{entry}, lines[end:]...)...)
 }
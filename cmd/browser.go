@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// browserTab is an open tab as reported by a browser backend
+type browserTab struct {
+	windowID  string
+	index     int
+	name      string
+	url       string
+	minimized bool
+	pinned    bool
+}
+
+// browserBackend drives one browser for the browser commands
+type browserBackend interface {
+	// ListTabs returns the tabs of all windows, in window and tab order
+	ListTabs() ([]browserTab, error)
+	// ActivateTab switches to the tab and raises its window, restoring it if
+	// minimized and restore is set
+	ActivateTab(tab browserTab, restore bool) error
+	CloseTab(tab browserTab) error
+}
+
+var browserBackends = map[string]browserBackend{
+	"safari": safariBackend{},
+}
+
+func lookupBrowserBackend(name string) (browserBackend, error) {
+	backend, ok := browserBackends[name]
+	if !ok {
+		names := make([]string, 0, len(browserBackends))
+		for name := range browserBackends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unsupported browser: %s (supported: %s)", name, strings.Join(names, ", "))
+	}
+	return backend, nil
+}
+
+// Use ASCII Unit Separator (0x1F) as delimiter - rarely appears in text
+const browserDelimiter = "\x1F"
+
+// markPinnedTabs flags pinned tabs for browsers whose scripting dictionary has
+// no pinned property. Pinned tabs lead the tab list of every window, so with
+// several windows a leading tab open at the same position everywhere is pinned.
+// The guess can't tell pinned tabs from tabs that happen to be open everywhere,
+// so it only marks them and every tab stays listed.
+func markPinnedTabs(tabs []browserTab) {
+	windows := []string{}
+	byWindow := map[string][]int{}
+	for i, tab := range tabs {
+		if _, ok := byWindow[tab.windowID]; !ok {
+			windows = append(windows, tab.windowID)
+		}
+		byWindow[tab.windowID] = append(byWindow[tab.windowID], i)
+	}
+	if len(windows) < 2 {
+		return
+	}
+
+	for position := 0; ; position++ {
+		first := byWindow[windows[0]]
+		if position >= len(first) {
+			return
+		}
+		url := tabs[first[position]].url
+		for _, window := range windows[1:] {
+			indexes := byWindow[window]
+			if position >= len(indexes) || tabs[indexes[position]].url != url {
+				return
+			}
+		}
+		for _, window := range windows {
+			tabs[byWindow[window][position]].pinned = true
+		}
+	}
+}
+
+// formatBrowserTabs renders the fzf input: window id, tab index, marks, name and url
+func formatBrowserTabs(tabs []browserTab) string {
+	var b strings.Builder
+	for _, tab := range tabs {
+		marks := ""
+		if tab.pinned {
+			marks += "[pin]"
+		}
+		if tab.minimized {
+			marks += "[min]"
+		}
+
+		// Truncate or pad to exactly 70 characters
+		name := []rune(strings.ReplaceAll(tab.name, "|", "¦"))
+		if len(name) > 70 {
+			name = append(name[:67], []rune("...")...)
+		}
+		padded := string(name) + strings.Repeat(" ", 70-len(name))
+
+		fmt.Fprintf(&b, "%s%s%d%s%-10s%s%s%s%s\n",
+			tab.windowID, browserDelimiter, tab.index, browserDelimiter, marks, browserDelimiter, padded, browserDelimiter, tab.url)
+	}
+	return b.String()
+}
+
+// parseBrowserSelection reads the tab back from a selected line of formatBrowserTabs
+func parseBrowserSelection(selection string) (browserTab, error) {
+	parts := strings.Split(selection, browserDelimiter)
+	if len(parts) < 5 {
+		return browserTab{}, fmt.Errorf("invalid selection format")
+	}
+	var index int
+	if _, err := fmt.Sscanf(parts[1], "%d", &index); err != nil {
+		return browserTab{}, fmt.Errorf("invalid selection format")
+	}
+	return browserTab{
+		windowID:  parts[0],
+		index:     index,
+		minimized: strings.Contains(parts[2], "[min]"),
+		pinned:    strings.Contains(parts[2], "[pin]"),
+		name:      strings.TrimSpace(parts[3]),
+		url:       strings.TrimSpace(parts[4]),
+	}, nil
+}
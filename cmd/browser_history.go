@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// tabHistoryMaxAge drops tabs from the history that weren't switched to for
+// this long, which keeps the state file small
+const tabHistoryMaxAge = 90 * 24 * time.Hour
+
+// tabVisit records how often and when a tab URL was switched to
+type tabVisit struct {
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// tabHistory maps a tab URL to its visits
+type tabHistory map[string]tabVisit
+
+func tabHistoryPath(browser string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "karabingen", browser+"_tabs.json"), nil
+}
+
+// readTabHistory returns the tab history of a browser, empty if there is none yet
+func readTabHistory(browser string) (tabHistory, error) {
+	path, err := tabHistoryPath(browser)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tabHistory{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tab history: %w", err)
+	}
+	history := tabHistory{}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse tab history %s: %w", path, err)
+	}
+	return history, nil
+}
+
+// recordTabSwitch counts a switch to the tab URL in the history of a browser
+func recordTabSwitch(browser, url string, now time.Time) error {
+	history, err := readTabHistory(browser)
+	if err != nil {
+		return err
+	}
+	visit := history[url]
+	visit.Count++
+	visit.LastUsed = now
+	history[url] = visit
+	for url, visit := range history {
+		if now.Sub(visit.LastUsed) > tabHistoryMaxAge {
+			delete(history, url)
+		}
+	}
+
+	path, err := tabHistoryPath(browser)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write tab history: %w", err)
+	}
+	return nil
+}
+
+// frecency scores a visit by how often and how recently the tab was used:
+// recent switches weigh more than old ones
+func (v tabVisit) frecency(now time.Time) float64 {
+	age := now.Sub(v.LastUsed)
+	weight := 0.25
+	switch {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(v.Count) * weight
+}
+
+// rankBrowserTabs sorts the tabs by frecency, the tabs never switched to keep
+// their window and tab order after the others
+func rankBrowserTabs(tabs []browserTab, history tabHistory, now time.Time) {
+	sort.SliceStable(tabs, func(i, j int) bool {
+		return history[tabs[i].url].frecency(now) > history[tabs[j].url].frecency(now)
+	})
+}
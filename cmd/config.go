@@ -1,92 +1,736 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// ModifierList is a list of modifier names that can also be written
+// as a single scalar in YAML (e.g. `optional: any`)
+type ModifierList []string
+
+func (m *ModifierList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*m = ModifierList{value.Value}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*m = list
+	return nil
+}
+
 // KeyBinding represents a single key binding configuration
 type KeyBinding struct {
-	Type string `yaml:"type"` // "app", "web", or "shell"
-	Val  string `yaml:"val"`
+	Type        string            `yaml:"type"` // "app", "web", "shell", "key", ... (see actions)
+	Val         string            `yaml:"val"`
+	Modifiers   ModifierList      `yaml:"modifiers"`   // modifiers sent along with a "key" binding
+	Optional    ModifierList      `yaml:"optional"`    // modifiers allowed to pass through (e.g. "any")
+	Method      string            `yaml:"method"`      // HTTP method of an "http" binding
+	Body        any               `yaml:"body"`        // JSON body of an "http" binding, string or mapping
+	Headers     map[string]string `yaml:"headers"`     // extra headers of an "http" binding
+	Actions     []KeyBinding      `yaml:"actions"`     // several actions run in order, instead of type/val
+	Open        string            `yaml:"open"`        // how an "app" binding opens the app, see createAppOpenTo
+	Description string            `yaml:"description"` // shown in rule descriptions instead of val
+	Icon        string            `yaml:"icon"`        // emoji put before the description
+}
+
+// LayerBinding is a layer sub-key binding. It can be written either as a plain
+// value, in which case the type is inherited from the layer, as a list of actions
+// or as a full binding
+type LayerBinding KeyBinding
+
+func (b *LayerBinding) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		b.Val = value.Value
+		return nil
+	case yaml.SequenceNode:
+		// A list is shorthand for actions
+		var actions []LayerBinding
+		if err := value.Decode(&actions); err != nil {
+			return err
+		}
+		for _, action := range actions {
+			b.Actions = append(b.Actions, KeyBinding(action))
+		}
+		return nil
+	}
+	return value.Decode((*KeyBinding)(b))
 }
 
 // LayerConfig represents a hyperkey layer configuration
 type LayerConfig struct {
-	Key  string            `yaml:"key"`
-	Type string            `yaml:"type"` // "app" or "web"
-	Sub  map[string]string `yaml:"sub"`
+	Key      string                  `yaml:"key"`
+	Type     string                  `yaml:"type"` // default type of the sub bindings, or "dynamic"
+	Sub      map[string]LayerBinding `yaml:"sub"`
+	Optional ModifierList            `yaml:"optional"`
+	// Command prints the "key value" sub bindings of a dynamic layer, of type SubType
+	Command string `yaml:"command"`
+	SubType string `yaml:"sub_type"`
+	// Mode is "hold" (active while the key is held) or "toggle" (latched until pressed again)
+	Mode         string `yaml:"mode"`
+	Notification string `yaml:"notification"` // message shown while a toggle layer is active
+	TimeoutMs    int    `yaml:"timeout_ms"`   // deactivate the layer after this much inactivity
+	Schedule     string `yaml:"schedule"`     // only active during this schedule
+	Description  string `yaml:"description"`  // rule description, e.g. "Navigation layer"
+	Icon         string `yaml:"icon"`         // emoji put before the description
+}
+
+// HJKLConfig represents the Option + H/J/K/L arrow keys configuration
+type HJKLConfig struct {
+	Modifier   string       `yaml:"modifier"` // "option" (either side), "left_option" or "right_option"
+	Optional   ModifierList `yaml:"optional"`
+	ExceptApps []string     `yaml:"except_apps"` // bundle identifiers of apps keeping option+h/j/k/l/m
 }
 
 // TmuxJumpConfig represents tmux session jumping configuration
 type TmuxJumpConfig struct {
-	Enable           bool     `yaml:"enable"`
+	Enable           *bool    `yaml:"enable"`
 	Modifiers        []string `yaml:"modifiers"`
 	JumplistPath     string   `yaml:"jumplist_path"`
 	Letters          []string `yaml:"letters"`
-	AllLetters       bool     `yaml:"all_letters"`
+	AllLetters       *bool    `yaml:"all_letters"`
 	AllLettersExcept []string `yaml:"all_letters_except"`
 	Terminal         string   `yaml:"terminal"`
-	TmuxPath         string   `yaml:"tmux_path"`
+	TmuxPath         string   `yaml:"tmux_path"`       // "auto" (default) looks tmux up like paths
+	Digits           []string `yaml:"digits"`          // digit keys jumping to sessions, [] disables them
+	DigitModifiers   []string `yaml:"digit_modifiers"` // extra modifiers for digit keys, e.g. [shift]
+	EditKey          string   `yaml:"edit_key"`        // key opening the jumplist in an editor, "" disables it
+	SessionName      string   `yaml:"session_name"`    // session name template of new bookmarks, e.g. "{parent}-{base}"
+	Socket           string   `yaml:"socket"`          // tmux server: socket name (-L) or socket path (-S)
+	TrackUsage       *bool    `yaml:"track_usage"`     // count jumps per key for "karabingen tmux stats"
+}
+
+// TerminalConfig holds the launch templates of a terminal emulator, shell
+// commands with the placeholders of expandTerminalTemplate
+type TerminalConfig struct {
+	App           string `yaml:"app"`             // application name, e.g. "WezTerm"
+	OpenNewWindow string `yaml:"open_new_window"` // opens a new window running the command
+	RunCommand    string `yaml:"run_command"`     // runs the command in the front window, typed by default
+	Popup         string `yaml:"popup"`           // opens a small centered window running the command
+}
+
+// CapsLockConfig keeps access to the real caps lock once caps_lock is remapped
+// by the hyper key or HHKB mode
+type CapsLockConfig struct {
+	ShiftToggle  *bool `yaml:"shift_toggle"`   // shift+caps_lock toggles caps lock
+	HoldToggleMs int   `yaml:"hold_toggle_ms"` // holding caps_lock alone this long toggles caps lock, 0 disables
+}
+
+// HyperConfig tunes the hyper key rule
+type HyperConfig struct {
+	Tap          string `yaml:"tap"`            // sent on a tap alone: "escape", "caps_lock", "none" or a key chord
+	TapTimeoutMs int    `yaml:"tap_timeout_ms"` // longer presses aren't taps, 0 keeps Karabiner's default
+	// Modifiers makes the hyper key hold cmd+ctrl+opt+shift for other tools,
+	// instead of setting the hyper variable karabingen's hyper bindings check
+	Modifiers *bool `yaml:"modifiers"`
+}
+
+// HHKBConfig represents HHKB mode options
+type HHKBConfig struct {
+	HyperOn string `yaml:"hyper_on"` // key acting as hyper while caps lock is left control
+}
+
+// PopupConfig represents the terminal window opened by "karabingen popup"
+type PopupConfig struct {
+	Terminal string `yaml:"terminal"` // a built-in terminal or one of the terminals section
+	Columns  int    `yaml:"columns"`
+	Lines    int    `yaml:"lines"`
+}
+
+// RuleGroupConfig merges the rules of several presets into one rule titled
+// with the group name, e.g. "Core" or "Experiments"
+type RuleGroupConfig struct {
+	Name    string   `yaml:"name"`
+	Presets []string `yaml:"presets"`
+	Enable  *bool    `yaml:"enable"` // false drops the rules of the group
+}
+
+// CustomRuleConfig is a rule written in the config, its manipulators in
+// Karabiner's own format with the shorthands of createCustomRule
+type CustomRuleConfig struct {
+	Description  string      `yaml:"description"`
+	Manipulators []yaml.Node `yaml:"manipulators"`
+}
+
+// ProfileConfig is a Karabiner profile, generated from the top-level settings
+// with the profile's own settings applied over them
+type ProfileConfig struct {
+	Name string
+
+	settings yaml.Node // the profile's settings as written
+	config   *Config   // the resolved configuration of the profile
+}
+
+func (p *ProfileConfig) UnmarshalYAML(value *yaml.Node) error {
+	var named struct {
+		Name     string    `yaml:"name"`
+		Profiles yaml.Node `yaml:"profiles"`
+	}
+	if err := value.Decode(&named); err != nil {
+		return err
+	}
+	if named.Name == "" {
+		return fmt.Errorf("line %d: profile without a name", value.Line)
+	}
+	if !named.Profiles.IsZero() {
+		return fmt.Errorf("line %d: profile %s cannot have profiles", value.Line, named.Name)
+	}
+	p.Name, p.settings = named.Name, *value
+	return nil
+}
+
+// MarshalYAML writes the resolved settings of a loaded profile, or the
+// settings as written before it is resolved
+func (p ProfileConfig) MarshalYAML() (any, error) {
+	if p.config == nil {
+		return &p.settings, nil
+	}
+	return struct {
+		Name    string `yaml:"name"`
+		*Config `yaml:",inline"`
+	}{p.Name, p.config}, nil
+}
+
+// setGenerating marks the config and its profiles as loaded by a generation
+// writing karabiner.json
+func (c *Config) setGenerating() {
+	c.generating = true
+	for i := range c.Profiles {
+		c.Profiles[i].config.generating = true
+	}
+}
+
+// setOutputPath records the karabiner.json the config and its profiles are
+// generated for, as an absolute path since commands run from launchd
+func (c *Config) setOutputPath(filePath string) {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+	c.outputPath = filePath
+	for i := range c.Profiles {
+		c.Profiles[i].config.outputPath = filePath
+	}
+}
+
+// profiles returns the profiles to generate, the config itself as "base"
+// without a profiles section
+func (c *Config) profiles() []ProfileConfig {
+	if len(c.Profiles) == 0 {
+		return []ProfileConfig{{Name: "base", config: c}}
+	}
+	return c.Profiles
+}
+
+// ParametersConfig represents profile-wide Karabiner timing parameters
+type ParametersConfig struct {
+	DelayBeforeOpenDeviceMs int `yaml:"delay_before_open_device_ms"`
+	ToIfAloneTimeoutMs      int `yaml:"to_if_alone_timeout_ms"`
+	ToIfHeldDownThresholdMs int `yaml:"to_if_held_down_threshold_ms"`
+	ToDelayedActionDelayMs  int `yaml:"to_delayed_action_delay_ms"`
+	SimultaneousThresholdMs int `yaml:"simultaneous_threshold_ms"`
+}
+
+// DeviceConfig represents the settings of one external keyboard, identified
+// by its vendor and product id as shown in Karabiner-EventViewer
+type DeviceConfig struct {
+	VendorID            int                  `yaml:"vendor_id"`
+	ProductID           int                  `yaml:"product_id"`
+	FnFunctionKeys      map[string]DeviceKey `yaml:"fn_function_keys"`     // f1..f12 -> output
+	SimpleModifications map[string]DeviceKey `yaml:"simple_modifications"` // key code -> output
+	ModifierPresets     []string             `yaml:"modifier_presets"`     // "pc", "fn_ctrl" or "unix"
+}
+
+// UnmarshalYAML lets a device key be a plain key code or a mapping
+// (e.g. {consumer_key_code: mission_control})
+func (k *DeviceKey) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*k = DeviceKey{KeyCode: value.Value}
+		return nil
+	}
+
+	type plain DeviceKey
+	var key plain
+	if err := value.Decode(&key); err != nil {
+		return err
+	}
+	*k = DeviceKey(key)
+	return nil
+}
+
+// ScheduleConfig is a weekly time window in which scheduled rules are active
+type ScheduleConfig struct {
+	Days []string `yaml:"days"` // mon, tue, ...; every day if empty
+	From string   `yaml:"from"` // start time, e.g. "09:00"
+	To   string   `yaml:"to"`   // end time, before From for windows past midnight
 }
 
 // FixG502Config represents G502 mouse button remapping configuration
 type FixG502Config struct {
-	Enable        bool   `yaml:"enable"`
-	SafariOnly    bool   `yaml:"safari_only"`
+	Enable        *bool  `yaml:"enable"`
+	SafariOnly    *bool  `yaml:"safari_only"`
 	BackButton    string `yaml:"back_button"`
 	ForwardButton string `yaml:"forward_button"`
 }
 
+// DoubleModifierConfig represents an action triggered by pressing a modifier twice quickly
+type DoubleModifierConfig struct {
+	Modifier   string `yaml:"modifier"` // e.g. "left_command", "left_shift"
+	DelayMs    int    `yaml:"delay_ms"`
+	KeyBinding `yaml:",inline"`
+}
+
+// HoldBindingConfig represents an alternate action triggered by holding an ordinary key
+type HoldBindingConfig struct {
+	Key         string `yaml:"key"`
+	ThresholdMs int    `yaml:"threshold_ms"`
+	KeyBinding  `yaml:",inline"`
+}
+
+// ModifierKeyConfig represents an action on a bare modifier key, e.g. tapping right_command
+type ModifierKeyConfig struct {
+	Key           string       `yaml:"key"`
+	FromModifiers ModifierList `yaml:"from_modifiers"` // other modifiers that must be held
+	Mode          string       `yaml:"mode"`           // "tap" (default) keeps the modifier when held, "replace" drops it
+	KeyBinding    `yaml:",inline"`
+}
+
+// LeaderSequenceConfig represents a key sequence typed after the leader key
+type LeaderSequenceConfig struct {
+	Keys       []string `yaml:"keys"`
+	KeyBinding `yaml:",inline"`
+}
+
+// LeaderConfig represents leader key sequences configuration
+type LeaderConfig struct {
+	Key       string                 `yaml:"key"`
+	Modifiers ModifierList           `yaml:"modifiers"`
+	TimeoutMs int                    `yaml:"timeout_ms"`
+	Sequences []LeaderSequenceConfig `yaml:"sequences"`
+}
+
+// SymbolsLayerConfig represents the programmer symbols layer preset
+type SymbolsLayerConfig struct {
+	Enable    *bool             `yaml:"enable"`
+	Key       string            `yaml:"key"`
+	Mode      string            `yaml:"mode"`
+	TimeoutMs int               `yaml:"timeout_ms"`
+	Layout    map[string]string `yaml:"layout"` // key -> symbol overrides
+}
+
+// WindowLayerConfig represents the window management layer preset
+type WindowLayerConfig struct {
+	Enable    *bool  `yaml:"enable"`
+	Key       string `yaml:"key"`
+	Manager   string `yaml:"manager"` // "yabai" or "aerospace"
+	Path      string `yaml:"path"`    // auto-detected if empty
+	Mode      string `yaml:"mode"`
+	TimeoutMs int    `yaml:"timeout_ms"`
+}
+
+// SpacesLayerConfig represents the macOS Spaces switching layer preset
+type SpacesLayerConfig struct {
+	Enable  *bool  `yaml:"enable"`
+	Key     string `yaml:"key"`
+	Backend string `yaml:"backend"` // "keys" (Mission Control shortcuts), "yabai" or "aerospace"
+	Path    string `yaml:"path"`    // yabai or aerospace binary, auto-detected if empty
+	Spaces  int    `yaml:"spaces"`  // number of Spaces on digit keys
+}
+
+// DisplayLayerConfig represents the multi-monitor control layer preset
+type DisplayLayerConfig struct {
+	Enable      *bool  `yaml:"enable"`
+	Key         string `yaml:"key"`
+	Displays    int    `yaml:"displays"`     // number of displays reachable with 1-9
+	WindowMover string `yaml:"window_mover"` // "yabai" or "rectangle", auto-detected if empty
+	Mirror      string `yaml:"mirror"`       // displayplacer arguments for the mirrored layout
+	Extend      string `yaml:"extend"`       // displayplacer arguments for the extended layout
+}
+
+// VolumeLayerConfig represents the volume/brightness layer preset
+type VolumeLayerConfig struct {
+	Enable *bool  `yaml:"enable"`
+	Key    string `yaml:"key"`
+	Fine   *bool  `yaml:"fine"` // adjust in quarter steps
+}
+
+// CharsLayerConfig represents the special characters layer preset
+type CharsLayerConfig struct {
+	Enable *bool             `yaml:"enable"`
+	Key    string            `yaml:"key"`
+	Chars  map[string]string `yaml:"chars"` // key -> character overrides
+}
+
+// UtilityLayerConfig represents the clipboard/screenshot layer preset
+type UtilityLayerConfig struct {
+	Enable    *bool  `yaml:"enable"`
+	Key       string `yaml:"key"`
+	Clipboard string `yaml:"clipboard"` // "raycast", "maccy" or "paste"
+}
+
+// SSHLayerConfig represents the layer preset opening ssh sessions in a terminal
+type SSHLayerConfig struct {
+	Enable     *bool             `yaml:"enable"`
+	Key        string            `yaml:"key"`
+	Hosts      map[string]string `yaml:"hosts"`       // sub key -> host, defaults to every host of config_path
+	ConfigPath string            `yaml:"config_path"` // ssh config the hosts are read from
+	Tmux       string            `yaml:"tmux"`        // remote tmux session to attach to, created if missing
+	Terminal   string            `yaml:"terminal"`    // defaults to the tmux_jump terminal
+	Mode       string            `yaml:"mode"`
+}
+
+// ObsidianConfig represents settings of the obsidian binding type
+type ObsidianConfig struct {
+	Vault string `yaml:"vault"` // defaults to the last opened vault
+	Inbox string `yaml:"inbox"` // note that quick captures are appended to
+}
+
+// MediaLayerConfig represents the music player control layer preset
+type MediaLayerConfig struct {
+	Enable *bool  `yaml:"enable"`
+	Key    string `yaml:"key"`
+	Player string `yaml:"player"` // "music" or "spotify"
+}
+
+// TriggerConfig represents the key combination that fires a preset
+type TriggerConfig struct {
+	Key       string       `yaml:"key"`
+	Modifiers ModifierList `yaml:"modifiers"`
+	Hyper     *bool        `yaml:"hyper"` // require the hyperkey to be held
+}
+
+// FunctionKeysToggleConfig represents the F1-F12 media/function keys toggle preset
+type FunctionKeysToggleConfig struct {
+	Enable        *bool `yaml:"enable"`
+	TriggerConfig `yaml:",inline"`
+	Notification  *bool `yaml:"notification"` // show a message while F-keys act as function keys
+}
+
+// PasswordManagerConfig represents the password manager quick access preset
+type PasswordManagerConfig struct {
+	Enable        *bool  `yaml:"enable"`
+	Manager       string `yaml:"manager"` // "1password" or "bitwarden"
+	TriggerConfig `yaml:",inline"`
+}
+
+// RegenerateConfig represents the preset binding regenerating karabiner.json
+// from the config, e.g. after pulling config changes
+type RegenerateConfig struct {
+	Enable        *bool `yaml:"enable"`
+	TriggerConfig `yaml:",inline"`
+}
+
+// CycleWindowsConfig represents the preset cycling through the windows of the frontmost app
+type CycleWindowsConfig struct {
+	Enable        *bool  `yaml:"enable"`
+	Manager       string `yaml:"manager"` // "applescript" (current space) or "yabai" (all spaces)
+	TriggerConfig `yaml:",inline"`
+}
+
 // KeybindingsConfig represents all keybindings configuration
 type KeybindingsConfig struct {
-	Option map[string]KeyBinding `yaml:"option"`
-	Layers []LayerConfig         `yaml:"layers"`
+	Option         map[string]KeyBinding `yaml:"option"`
+	OptionModifier string                `yaml:"option_modifier"` // modifier of "option" bindings, left_option by default
+	Layers         []LayerConfig         `yaml:"layers"`
 }
 
 // Config represents the complete configuration
 type Config struct {
-	Version            int               `yaml:"version"`
-	DisableCommandTab  bool              `yaml:"disable_command_tab"`
-	DisableLeftCtrl    bool              `yaml:"disable_left_ctrl"`
-	FixCC              bool             `yaml:"fix_c_c"`
-	UseHHKB            bool              `yaml:"use_hhkb"`
-	Hyperkey           string            `yaml:"hyperkey"`
-	Keybindings        KeybindingsConfig `yaml:"keybindings"`
-	TmuxJump           TmuxJumpConfig    `yaml:"tmux_jump"`
-	FixG502            FixG502Config     `yaml:"fix_g502"`
-	SwitchSafariTabsHL bool              `yaml:"switch_safari_tabs_hl"`
+	Version            int                         `yaml:"version"`
+	DisableCommandTab  *bool                       `yaml:"disable_command_tab"`
+	DisableLeftCtrl    *bool                       `yaml:"disable_left_ctrl"`
+	FixCC              *bool                       `yaml:"fix_c_c"`
+	UseHHKB            *bool                       `yaml:"use_hhkb"`
+	Hyperkey           string                      `yaml:"hyperkey"`
+	Hyper              HyperConfig                 `yaml:"hyper"`
+	CapsLock           CapsLockConfig              `yaml:"caps_lock"`
+	HHKB               HHKBConfig                  `yaml:"hhkb"`
+	Keybindings        KeybindingsConfig           `yaml:"keybindings"`
+	TmuxJump           TmuxJumpConfig              `yaml:"tmux_jump"`
+	FixG502            FixG502Config               `yaml:"fix_g502"`
+	SwitchSafariTabsHL *bool                       `yaml:"switch_safari_tabs_hl"`
+	BothShiftsCapsLock *bool                       `yaml:"both_shifts_caps_lock"`
+	HJKL               HJKLConfig                  `yaml:"hjkl"`
+	DoubleModifiers    []DoubleModifierConfig      `yaml:"double_modifiers"`
+	HoldBindings       []HoldBindingConfig         `yaml:"hold_bindings"`
+	ModifierKeys       []ModifierKeyConfig         `yaml:"modifier_keys"`
+	Leader             LeaderConfig                `yaml:"leader"`
+	SymbolsLayer       SymbolsLayerConfig          `yaml:"symbols_layer"`
+	FunctionKeysToggle FunctionKeysToggleConfig    `yaml:"function_keys_toggle"`
+	CycleWindows       CycleWindowsConfig          `yaml:"cycle_windows"`
+	WindowLayer        WindowLayerConfig           `yaml:"window_layer"`
+	SpacesLayer        SpacesLayerConfig           `yaml:"spaces_layer"`
+	DisplayLayer       DisplayLayerConfig          `yaml:"display_layer"`
+	VolumeLayer        VolumeLayerConfig           `yaml:"volume_layer"`
+	CharsLayer         CharsLayerConfig            `yaml:"chars_layer"`
+	UtilityLayer       UtilityLayerConfig          `yaml:"utility_layer"`
+	MediaLayer         MediaLayerConfig            `yaml:"media_layer"`
+	SSHLayer           SSHLayerConfig              `yaml:"ssh_layer"`
+	PasswordManager    PasswordManagerConfig       `yaml:"password_manager"`
+	Regenerate         RegenerateConfig            `yaml:"regenerate"`
+	Obsidian           ObsidianConfig              `yaml:"obsidian"`
+	ProjectEditor      string                      `yaml:"project_editor"`     // command used by "editor" bindings, e.g. "zed"
+	Paths              map[string]string           `yaml:"paths"`              // tool name -> binary path overrides
+	Terminals          map[string]TerminalConfig   `yaml:"terminals"`          // terminal name -> launch templates
+	AppOpen            string                      `yaml:"app_open"`           // default open of "app" bindings
+	Aliases            map[string]string           `yaml:"aliases"`            // alias name -> shell command for "karabingen run"
+	GitCommit          *bool                       `yaml:"git_commit"`         // commit karabiner.json when its directory is a git repo
+	Notify             *bool                       `yaml:"notify"`             // post a macOS notification after generate
+	MaxManipulators    int                         `yaml:"max_manipulators"`   // generate refuses bigger rules without --force, 0 disables the check
+	OptionalModifiers  ModifierList                `yaml:"optional_modifiers"` // default "optional" of every binding
+	DescriptionTag     string                      `yaml:"description_tag"`    // prefix of generated rule descriptions, "" disables it
+	FzfOptions         []string                    `yaml:"fzf_options"`        // extra options of the fzf pickers
+	Popup              PopupConfig                 `yaml:"popup"`
+	Parameters         ParametersConfig            `yaml:"parameters"`
+	RuleParameters     map[string]ParametersConfig `yaml:"rule_parameters"`     // preset name -> per-rule overrides
+	Schedules          map[string]ScheduleConfig   `yaml:"schedules"`           // schedule name -> time window
+	RuleSchedules      map[string]string           `yaml:"rule_schedules"`      // preset name -> schedule name
+	RuleExceptApps     map[string][]string         `yaml:"rule_except_apps"`    // preset name -> bundle identifiers of apps the rules skip
+	RuleGroups         []RuleGroupConfig           `yaml:"rule_groups"`         // presets merged into titled rules, in this order
+	Devices            map[string]DeviceConfig     `yaml:"devices"`             // device name -> per-device settings
+	RuleDevices        map[string][]string         `yaml:"rule_devices"`        // preset name -> devices the rules are limited to
+	RuleExceptDevices  map[string][]string         `yaml:"rule_except_devices"` // preset name -> devices the rules skip
+	ModifierPresets    []string                    `yaml:"modifier_presets"`    // modifier swaps of every keyboard, "pc", "fn_ctrl" or "unix"
+	Profiles           []ProfileConfig             `yaml:"profiles"`            // Karabiner profiles to generate instead of "base"
+	CustomRules        []CustomRuleConfig          `yaml:"custom_rules"`        // rules written as Karabiner manipulators, ahead of the presets
+	AllowCommands      *bool                       `yaml:"allow_commands"`      // run the commands of dynamic layers when generating
+
+	path       string // absolute path of the loaded config file
+	hash       string // sha256 of the loaded config file
+	generating bool   // loaded by generate to write karabiner.json, the only time commands run
+	outputPath string // karabiner.json the rules are generated for
+}
+
+// expandPath expands environment variables and a leading ~ in a path
+func expandPath(path string) (string, error) {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return path, nil
+}
+
+// expandDir expands a path and checks that it is an existing directory
+func expandDir(path string) (string, error) {
+	dir, err := expandPath(path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("directory %s does not exist", dir)
+	}
+	return dir, nil
+}
+
+// resolveHyperkey decides which key becomes hyper. HHKB mode turns caps lock into
+// left control, so it only gets a hyper key through hhkb.hyper_on (or hyperkey).
+// An empty Hyperkey afterwards means no hyper rule is generated.
+func resolveHyperkey(config *Config) error {
+	if !boolValue(config.UseHHKB, false) {
+		if config.HHKB.HyperOn != "" {
+			return fmt.Errorf("hhkb.hyper_on requires use_hhkb: true, use hyperkey instead")
+		}
+		if config.Hyperkey == "" {
+			config.Hyperkey = "caps_lock"
+		}
+		return nil
+	}
+
+	if config.Hyperkey == "caps_lock" || config.HHKB.HyperOn == "caps_lock" {
+		return fmt.Errorf("use_hhkb maps caps_lock to left_control, so it cannot be the hyper key; set hhkb.hyper_on to another key")
+	}
+	if config.HHKB.HyperOn != "" && config.Hyperkey != "" && config.HHKB.HyperOn != config.Hyperkey {
+		return fmt.Errorf("hyperkey %s contradicts hhkb.hyper_on %s", config.Hyperkey, config.HHKB.HyperOn)
+	}
+	if config.HHKB.HyperOn != "" {
+		config.Hyperkey = config.HHKB.HyperOn
+	}
+	return nil
+}
+
+// boolValue returns the value of an optional boolean, or def when it is unset.
+// Options are *bool so that an absent key and an explicit false can be told apart.
+func boolValue(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
+// optionalModifiers resolves the modifiers allowed to pass through a binding:
+// the first list that is set wins, then the global optional_modifiers, then fallback
+func (c *Config) optionalModifiers(fallback []string, lists ...ModifierList) []string {
+	for _, list := range lists {
+		if list != nil {
+			return list
+		}
+	}
+	if c.OptionalModifiers != nil {
+		return c.OptionalModifiers
+	}
+	return fallback
+}
+
+// toolPath returns the configured path of an external tool, looking it up if
+// not set or "auto"
+func (c *Config) toolPath(name string) string {
+	if path := c.Paths[name]; path != "" && path != "auto" {
+		return path
+	}
+	return findExecutable(name)
+}
+
+// tmuxPath returns the tmux binary of tmux_jump, or "" when it is looked up
+// where it runs
+func (c *Config) tmuxPath() string {
+	if c.TmuxJump.TmuxPath != "auto" {
+		return c.TmuxJump.TmuxPath
+	}
+	if path := c.Paths["tmux"]; path != "auto" {
+		return path
+	}
+	return ""
 }
 
 func loadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	var data []byte
+	var err error
+	configPath := path
+	if isRemoteConfig(path) {
+		data, err = fetchRemoteConfig(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		configPath, err = filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute config path: %w", err)
+		}
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	config, err := decodeConfig(data, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
+	config.path, config.hash = configPath, hash
 
+	// Every profile decodes the whole file again with its settings on top
+	seen := map[string]bool{}
+	for i := range config.Profiles {
+		profile := &config.Profiles[i]
+		if seen[profile.Name] {
+			return nil, fmt.Errorf("duplicate profile %s", profile.Name)
+		}
+		seen[profile.Name] = true
+
+		profile.config, err = decodeConfig(data, &profile.settings)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		profile.config.Profiles = nil
+		profile.config.path, profile.config.hash = configPath, hash
+	}
+	return config, nil
+}
+
+// decodeConfig parses a config file, applying the settings of a profile over
+// the top-level ones when given
+func decodeConfig(data []byte, profile *yaml.Node) (*Config, error) {
 	var config Config
+
 	// Set defaults
 	config.Version = 1
-	config.Hyperkey = "caps_lock"
 	config.TmuxJump.Terminal = "alacritty"
 	config.TmuxJump.Modifiers = []string{"option", "control"}
 	config.TmuxJump.JumplistPath = "~/.tmuxjumplist"
-	config.TmuxJump.TmuxPath = "/opt/homebrew/bin/tmux"
-	config.FixG502.SafariOnly = true
+	config.TmuxJump.TmuxPath = "auto"
+	config.TmuxJump.Digits = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	config.TmuxJump.EditKey = "0"
+	config.TmuxJump.SessionName = "{base}"
 	config.FixG502.BackButton = "button4"
 	config.FixG502.ForwardButton = "button5"
+	config.Leader.TimeoutMs = 1000
+	config.DescriptionTag = "[karabingen]"
+	config.Popup.Columns = 100
+	config.Popup.Lines = 30
+	config.Keybindings.OptionModifier = "left_option"
+	config.Hyper.Tap = "escape"
+	config.HJKL.Modifier = "option"
+	config.SymbolsLayer.Key = "s"
+	config.WindowLayer.Key = "m"
+	config.WindowLayer.Manager = "yabai"
+	config.SpacesLayer.Key = "spacebar"
+	config.SpacesLayer.Backend = "keys"
+	config.SpacesLayer.Spaces = 9
+	config.DisplayLayer.Key = "d"
+	config.DisplayLayer.Displays = 2
+	config.VolumeLayer.Key = "v"
+	config.CharsLayer.Key = "c"
+	config.UtilityLayer.Key = "u"
+	config.MediaLayer.Key = "a"
+	config.MediaLayer.Player = "music"
+	config.SSHLayer.Key = "r"
+	config.SSHLayer.ConfigPath = "~/.ssh/config"
+	config.PasswordManager.Manager = "1password"
+	config.PasswordManager.Key = "p"
+	config.Regenerate.Key = "f5"
+	config.Obsidian.Inbox = "Inbox"
+	config.ProjectEditor = "code"
+	config.FunctionKeysToggle.Key = "escape"
+	config.FunctionKeysToggle.Modifiers = []string{"fn"}
+	config.CycleWindows.Manager = "applescript"
+	config.CycleWindows.Key = "grave_accent_and_tilde"
+	config.CycleWindows.Modifiers = []string{"option"}
+	config.AppOpen = "launch"
+	config.MaxManipulators = 100
 
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
+	if profile != nil {
+		if err := profile.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	}
 
 	// Validate version
 	if config.Version != 1 {
 		return nil, fmt.Errorf("unsupported config version: %d (supported: 1)", config.Version)
 	}
 
+	if err := resolveHyperkey(&config); err != nil {
+		return nil, err
+	}
+
+	// Popups and ssh sessions open in the tmux_jump terminal unless configured otherwise
+	if config.Popup.Terminal == "" {
+		config.Popup.Terminal = config.TmuxJump.Terminal
+	}
+	if config.SSHLayer.Terminal == "" {
+		config.SSHLayer.Terminal = config.TmuxJump.Terminal
+	}
+
 	// Process all_letters_except or all_letters
 	if config.TmuxJump.AllLettersExcept != nil {
 		allLetters := "abcdefghijklmnopqrstuvwxyz"
@@ -103,7 +747,7 @@ func loadConfig(path string) (*Config, error) {
 				config.TmuxJump.Letters = append(config.TmuxJump.Letters, string(char))
 			}
 		}
-	} else if config.TmuxJump.AllLetters {
+	} else if boolValue(config.TmuxJump.AllLetters, false) {
 		config.TmuxJump.Letters = []string{}
 		for char := 'a'; char <= 'z'; char++ {
 			config.TmuxJump.Letters = append(config.TmuxJump.Letters, string(char))
@@ -112,3 +756,46 @@ func loadConfig(path string) (*Config, error) {
 
 	return &config, nil
 }
+
+// defaultConfigPath locates the active config: $KARABINGEN_CONFIG (a path or URL)
+// or ~/.config/karabingen/config.y(a)ml
+func defaultConfigPath() (string, error) {
+	if path := os.Getenv("KARABINGEN_CONFIG"); path != "" {
+		if isRemoteConfig(path) {
+			return path, nil
+		}
+		return expandPath(path)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	for _, name := range []string{"config.yaml", "config.yml"} {
+		path := filepath.Join(home, ".config", "karabingen", name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", errNoConfig
+}
+
+// errNoConfig means no config path was given and there is no default config
+var errNoConfig = errors.New("no config found, pass a path or set KARABINGEN_CONFIG")
+
+// resolveConfigPath returns configPath, or the default config when it is empty
+func resolveConfigPath(configPath string) (string, error) {
+	if configPath != "" {
+		return configPath, nil
+	}
+	return defaultConfigPath()
+}
+
+// loadDefaultConfig loads configPath, or the default config when it is empty
+func loadDefaultConfig(configPath string) (*Config, error) {
+	configPath, err := resolveConfigPath(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return loadConfig(configPath)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Configuration utilities",
+	Long:  `Commands for inspecting the YAML configuration.`,
+}
+
+var showConfigCmd = &cobra.Command{
+	Use:   "show [config_path|config_url]",
+	Short: "Print the effective configuration",
+	Long: `Print the configuration as the generator sees it, with defaults applied and
+all_letters/all_letters_except expanded.
+Without an argument, uses $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := ""
+		if len(args) > 0 {
+			configPath = args[0]
+		}
+		return showConfig(configPath)
+	},
+}
+
+func showConfig(configPath string) error {
+	config, err := loadDefaultConfig(configPath)
+	if err != nil {
+		return err
+	}
+	applyBoolDefaults(config)
+	for _, profile := range config.Profiles {
+		applyBoolDefaults(profile.config)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	pruneEmptyNodes(&node)
+
+	fmt.Printf("# Effective configuration of %s\n", config.path)
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+// applyBoolDefaults sets the unset switches of config to the defaults the
+// generators read them with, so they print as what takes effect
+func applyBoolDefaults(config *Config) {
+	set := func(b **bool, def bool) {
+		value := boolValue(*b, def)
+		*b = &value
+	}
+	set(&config.DisableCommandTab, false)
+	set(&config.DisableLeftCtrl, false)
+	set(&config.FixCC, false)
+	set(&config.UseHHKB, false)
+	set(&config.SwitchSafariTabsHL, false)
+	set(&config.BothShiftsCapsLock, false)
+	set(&config.GitCommit, false)
+	set(&config.Notify, false)
+	set(&config.AllowCommands, false)
+	set(&config.Hyper.Modifiers, false)
+	set(&config.CapsLock.ShiftToggle, false)
+	set(&config.TmuxJump.Enable, false)
+	set(&config.TmuxJump.AllLetters, false)
+	set(&config.TmuxJump.TrackUsage, false)
+	set(&config.FixG502.Enable, false)
+	set(&config.FixG502.SafariOnly, true)
+	set(&config.SymbolsLayer.Enable, false)
+	set(&config.WindowLayer.Enable, false)
+	set(&config.SpacesLayer.Enable, false)
+	set(&config.DisplayLayer.Enable, false)
+	set(&config.VolumeLayer.Enable, false)
+	set(&config.VolumeLayer.Fine, true)
+	set(&config.CharsLayer.Enable, false)
+	set(&config.UtilityLayer.Enable, false)
+	set(&config.MediaLayer.Enable, false)
+	set(&config.SSHLayer.Enable, false)
+	set(&config.FunctionKeysToggle.Enable, false)
+	set(&config.FunctionKeysToggle.Hyper, false)
+	set(&config.FunctionKeysToggle.Notification, false)
+	set(&config.CycleWindows.Enable, false)
+	set(&config.CycleWindows.Hyper, false)
+	set(&config.PasswordManager.Enable, false)
+	set(&config.PasswordManager.Hyper, true)
+	set(&config.Regenerate.Enable, false)
+	set(&config.Regenerate.Hyper, true)
+	for i := range config.RuleGroups {
+		set(&config.RuleGroups[i].Enable, true)
+	}
+}
+
+// pruneEmptyNodes drops unset values (empty strings, nulls, empty lists and maps)
+// from mappings to keep the output readable
+func pruneEmptyNodes(node *yaml.Node) {
+	for _, child := range node.Content {
+		pruneEmptyNodes(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	content := node.Content[:0]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := node.Content[i+1]
+		empty := false
+		switch value.Kind {
+		case yaml.ScalarNode:
+			empty = value.Tag == "!!null" || (value.Tag == "!!str" && value.Value == "")
+		case yaml.SequenceNode, yaml.MappingNode:
+			empty = len(value.Content) == 0
+		}
+		if !empty {
+			content = append(content, node.Content[i], value)
+		}
+	}
+	node.Content = content
+}
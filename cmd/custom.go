@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// customEventKeys are the manipulator fields holding lists of to events
+var customEventKeys = []string{"to", "to_if_alone", "to_if_held_down", "to_after_key_up"}
+
+// createCustomRule turns a custom_rules entry into a rule. Manipulators use
+// Karabiner's field names, with a few shorthands: type defaults to "basic",
+// and from or a to event may be a key chord like "cmd+shift+4" or a binding
+// like {type: web, val: https://example.com}.
+func createCustomRule(config *Config, custom CustomRuleConfig) (Rule, error) {
+	if custom.Description == "" {
+		return Rule{}, fmt.Errorf("custom rule without a description")
+	}
+	if len(custom.Manipulators) == 0 {
+		return Rule{}, fmt.Errorf("custom rule %q has no manipulators", custom.Description)
+	}
+
+	rule := Rule{Description: custom.Description}
+	for i, node := range custom.Manipulators {
+		manipulator, err := decodeCustomManipulator(config, node)
+		if err != nil {
+			return Rule{}, fmt.Errorf("custom rule %q manipulator %d: %w", custom.Description, i+1, err)
+		}
+		rule.Manipulators = append(rule.Manipulators, manipulator)
+	}
+	return rule, nil
+}
+
+func decodeCustomManipulator(config *Config, node yaml.Node) (Manipulator, error) {
+	var fields map[string]any
+	if err := node.Decode(&fields); err != nil {
+		return Manipulator{}, err
+	}
+	if fields == nil {
+		return Manipulator{}, fmt.Errorf("expected a mapping")
+	}
+
+	if _, ok := fields["type"]; !ok {
+		fields["type"] = "basic"
+	}
+	if chord, ok := fields["from"].(string); ok {
+		key, modifiers, err := parseKeyChord(chord)
+		if err != nil {
+			return Manipulator{}, err
+		}
+		from := map[string]any{"key_code": key}
+		if len(modifiers) > 0 {
+			from["modifiers"] = map[string]any{"mandatory": modifiers}
+		}
+		fields["from"] = from
+	}
+	for _, name := range customEventKeys {
+		events, err := expandCustomEvents(config, fields[name])
+		if err != nil {
+			return Manipulator{}, fmt.Errorf("%s: %w", name, err)
+		}
+		if events != nil {
+			fields[name] = events
+		}
+	}
+
+	// A JSON round trip maps the fields onto Manipulator, rejecting unknown ones
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return Manipulator{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var manipulator Manipulator
+	if err := decoder.Decode(&manipulator); err != nil {
+		return Manipulator{}, err
+	}
+	if manipulator.From.KeyCode == "" && manipulator.From.Any == "" && manipulator.From.PointingButton == "" && len(manipulator.From.Simultaneous) == 0 {
+		return Manipulator{}, fmt.Errorf("from has no key")
+	}
+	return manipulator, nil
+}
+
+// expandCustomEvents turns key chords and bindings in a list of to events, or
+// a single event, into Karabiner events
+func expandCustomEvents(config *Config, value any) ([]any, error) {
+	var events []any
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		events = value
+	default:
+		events = []any{value}
+	}
+
+	expanded := make([]any, 0, len(events))
+	for _, event := range events {
+		if fields, ok := event.(map[string]any); ok && fields["type"] != nil {
+			tos, err := customBindingTos(config, fields)
+			if err != nil {
+				return nil, err
+			}
+			for _, to := range tos {
+				expanded = append(expanded, to)
+			}
+			continue
+		}
+		chord, ok := event.(string)
+		if !ok {
+			expanded = append(expanded, event)
+			continue
+		}
+		key, modifiers, err := parseKeyChord(chord)
+		if err != nil {
+			return nil, err
+		}
+		to := map[string]any{"key_code": key}
+		if len(modifiers) > 0 {
+			to["modifiers"] = modifiers
+		}
+		expanded = append(expanded, to)
+	}
+	return expanded, nil
+}
+
+// customBindingTos generates the events of a binding written as a to event
+func customBindingTos(config *Config, fields map[string]any) ([]To, error) {
+	data, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var binding KeyBinding
+	if err := yaml.Unmarshal(data, &binding); err != nil {
+		return nil, err
+	}
+	return createBindingTos(config, binding)
+}
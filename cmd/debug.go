@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	debugLogPaths   []string
+	debugLines      int
+	debugConfigPath string
+	debugStatePath  string
+	debugAllVars    bool
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Debugging helpers",
+	Long:  `Commands for checking whether generated rules match, without the Karabiner-Elements GUI.`,
+}
+
+var eventsDebugCmd = &cobra.Command{
+	Use:   "events [filter...]",
+	Short: "Tail the Karabiner logs",
+	Long: `Print the last lines of the Karabiner logs and follow them, like "tail -f".
+With filters, only lines containing one of them are shown, e.g. a key code or a
+variable name:
+
+  karabingen debug events hyper_sublayer_w caps_lock`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths := debugLogPaths
+		if len(paths) == 0 {
+			var err error
+			paths, err = karabinerLogPaths()
+			if err != nil {
+				return err
+			}
+		}
+		return tailLogs(os.Stdout, paths, args, debugLines)
+	},
+}
+
+var varsDebugCmd = &cobra.Command{
+	Use:   "vars",
+	Short: "Print the current values of the karabingen variables",
+	Long: `Print the current values of the Karabiner variables used by the generated rules
+(hyper, hyper_sublayer_*, schedule_*, ...), read from Karabiner's state file,
+e.g. to find a stuck layer. Unset variables count as 0 in conditions.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var names []string
+		if !debugAllVars {
+			config, err := loadDefaultConfig(debugConfigPath)
+			if err != nil {
+				return err
+			}
+			presetRules, err := createRules(config)
+			if err != nil {
+				return err
+			}
+			names = ruleVariables(presetRules)
+		}
+		return printVariables(os.Stdout, debugStatePath, names)
+	},
+}
+
+func init() {
+	eventsDebugCmd.Flags().StringArrayVar(&debugLogPaths, "log", nil, "Log file to follow (repeatable, default: the Karabiner logs)")
+	eventsDebugCmd.Flags().IntVarP(&debugLines, "lines", "n", 20, "Number of existing lines to print first")
+	varsDebugCmd.Flags().StringVar(&debugConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	varsDebugCmd.Flags().StringVar(&debugStatePath, "state", "", "Karabiner manipulator environment file (default: found in "+karabinerStateDir+")")
+	varsDebugCmd.Flags().BoolVar(&debugAllVars, "all", false, "Print every variable, not only the ones used by the config")
+}
+
+// karabinerLogPaths returns the Karabiner log files that exist
+func karabinerLogPaths() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	var paths []string
+	for _, path := range []string{
+		filepath.Join(home, ".local", "share", "karabiner", "log", "console_user_server.log"),
+		"/var/log/karabiner/core_service.log",
+	} {
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no Karabiner logs found, pass one with --log")
+	}
+	return paths, nil
+}
+
+// logLineMatches reports whether line contains one of the filters
+func logLineMatches(line string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if strings.Contains(line, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// tailLogs prints the last lines of every log and then follows them until
+// interrupted. Lines are prefixed with the log name when following several logs.
+func tailLogs(w io.Writer, paths, filters []string, lines int) error {
+	var mu sync.Mutex
+	print := func(path, line string) {
+		if !logLineMatches(line, filters) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(paths) > 1 {
+			fmt.Fprintf(w, "%s: ", strings.TrimSuffix(filepath.Base(path), ".log"))
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	errs := make(chan error, len(paths))
+	for _, path := range paths {
+		go func() {
+			errs <- followLog(path, lines, filters, func(line string) { print(path, line) })
+		}()
+	}
+	// Followers only return on errors
+	return <-errs
+}
+
+// followLog prints the last matching lines of the log at path and then polls
+// it for new lines, reopening it when it's rotated or truncated
+func followLog(path string, lines int, filters []string, print func(string)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var last []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line != "" && logLineMatches(line, filters) {
+			last = append(last, line)
+		}
+	}
+	for _, line := range last[max(len(last)-lines, 0):] {
+		print(line)
+	}
+
+	offset := int64(len(data))
+	var partial string
+	for {
+		time.Sleep(250 * time.Millisecond)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// Rotation in progress
+			continue
+		}
+		if info.Size() < offset {
+			offset, partial = 0, ""
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		reader := bufio.NewReader(file)
+		for {
+			chunk, err := reader.ReadString('\n')
+			offset += int64(len(chunk))
+			if err != nil {
+				// Keep an unterminated line until the rest is written
+				partial += chunk
+				break
+			}
+			print(strings.TrimRight(partial+chunk, "\n"))
+			partial = ""
+		}
+		file.Close()
+	}
+}
+
+// karabinerStateDir holds the manipulator environment Karabiner-EventViewer
+// shows in its Variables tab
+const karabinerStateDir = "/Library/Application Support/org.pqrs/tmp"
+
+// karabinerStatePath returns the manipulator environment file of the
+// installed Karabiner version
+func karabinerStatePath() (string, error) {
+	for _, name := range []string{
+		"karabiner_core_service_manipulator_environment.json",
+		"karabiner_grabber_manipulator_environment.json",
+	} {
+		path := filepath.Join(karabinerStateDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no Karabiner state file found in %s, pass one with --state", karabinerStateDir)
+}
+
+// ruleVariables returns the sorted names of the variables the rules depend on
+func ruleVariables(presetRules []presetRule) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, generated := range presetRules {
+		for _, m := range generated.rule.Manipulators {
+			for _, c := range m.Conditions {
+				if strings.HasPrefix(c.Type, "variable_") && !seen[c.Name] {
+					seen[c.Name] = true
+					names = append(names, c.Name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printVariables prints the current values of the named variables, or of all
+// variables if names is nil
+func printVariables(w io.Writer, statePath string, names []string) error {
+	if statePath == "" {
+		var err error
+		statePath, err = karabinerStatePath()
+		if err != nil {
+			return err
+		}
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	var state struct {
+		Variables map[string]any `json:"variables"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	if names == nil {
+		for name := range state.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		value, ok := state.Variables[name]
+		if !ok {
+			fmt.Fprintf(tw, "%s\t-\n", name)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%v\n", name, value)
+	}
+	return tw.Flush()
+}
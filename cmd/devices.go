@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// createDevices builds the per-device settings of the config, sorted by name
+func createDevices(config *Config) ([]Device, error) {
+	names := make([]string, 0, len(config.Devices))
+	for name := range config.Devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	devices := make([]Device, 0, len(names))
+	for _, name := range names {
+		device := config.Devices[name]
+		if device.VendorID == 0 || device.ProductID == 0 {
+			return nil, fmt.Errorf("device %s: vendor_id and product_id are required", name)
+		}
+		// Devices only named for rule_devices keep their Karabiner-Elements settings
+		if len(device.FnFunctionKeys) == 0 && len(device.SimpleModifications) == 0 && len(device.ModifierPresets) == 0 {
+			continue
+		}
+
+		fnFunctionKeys, err := createDeviceModifications(device.FnFunctionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("device %s fn_function_keys: %w", name, err)
+		}
+		// Explicit simple modifications win over the presets
+		presetKeys, err := resolveModifierPresets(device.ModifierPresets)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", name, err)
+		}
+		keys := map[string]DeviceKey{}
+		for from, to := range presetKeys {
+			keys[from] = DeviceKey{KeyCode: to}
+		}
+		for from, to := range device.SimpleModifications {
+			keys[from] = to
+		}
+		simpleModifications, err := createDeviceModifications(keys)
+		if err != nil {
+			return nil, fmt.Errorf("device %s simple_modifications: %w", name, err)
+		}
+
+		devices = append(devices, Device{
+			Identifiers: DeviceIdentifiers{
+				IsKeyboard: true,
+				ProductID:  device.ProductID,
+				VendorID:   device.VendorID,
+			},
+			FnFunctionKeys:      fnFunctionKeys,
+			SimpleModifications: simpleModifications,
+		})
+	}
+	return devices, nil
+}
+
+// deviceIdentifiers looks up the identifiers of devices by name
+func deviceIdentifiers(config *Config, names []string) ([]DeviceIdentifier, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no devices")
+	}
+	identifiers := make([]DeviceIdentifier, len(names))
+	for i, name := range names {
+		device, ok := config.Devices[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown device %s, add it to devices", name)
+		}
+		if device.VendorID == 0 || device.ProductID == 0 {
+			return nil, fmt.Errorf("device %s: vendor_id and product_id are required", name)
+		}
+		identifiers[i] = DeviceIdentifier{VendorID: device.VendorID, ProductID: device.ProductID}
+	}
+	return identifiers, nil
+}
+
+// restrictDevices adds a device condition of conditionType ("device_if" or
+// "device_unless") to every manipulator of the rule
+func restrictDevices(rule Rule, conditionType string, identifiers []DeviceIdentifier) Rule {
+	manipulators := make([]Manipulator, len(rule.Manipulators))
+	for i, m := range rule.Manipulators {
+		m.Conditions = append(slices.Clone(m.Conditions), Condition{Type: conditionType, Identifiers: identifiers})
+		manipulators[i] = m
+	}
+	rule.Manipulators = manipulators
+	return rule
+}
+
+// createDeviceModifications converts a from key -> output mapping, sorted by from key
+func createDeviceModifications(keys map[string]DeviceKey) ([]DeviceModification, error) {
+	froms := make([]string, 0, len(keys))
+	for from := range keys {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	modifications := make([]DeviceModification, 0, len(froms))
+	for _, from := range froms {
+		if err := validateKeyCode("key_code", from); err != nil {
+			return nil, err
+		}
+		to := keys[from]
+		set := 0
+		for _, value := range []string{to.KeyCode, to.ConsumerKeyCode, to.PointingButton} {
+			if value != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("%s: expected exactly one of key_code, consumer_key_code and pointing_button", from)
+		}
+		if to.KeyCode != "" {
+			if err := validateKeyCode("key_code", to.KeyCode); err != nil {
+				return nil, err
+			}
+		}
+		if to.ConsumerKeyCode != "" {
+			if err := validateKeyCode("consumer_key_code", to.ConsumerKeyCode); err != nil {
+				return nil, err
+			}
+		}
+		modifications = append(modifications, DeviceModification{
+			From: DeviceKey{KeyCode: from},
+			To:   []DeviceKey{to},
+		})
+	}
+	return modifications, nil
+}
+
+// mergeDevices adds the generated devices to the existing device entries. An
+// existing entry for the same keyboard keeps its other settings (e.g. "ignore")
+// and gets the configured modifications.
+func mergeDevices(existing []interface{}, devices []Device) ([]interface{}, error) {
+	for _, device := range devices {
+		data, err := json.Marshal(device)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		var generated map[string]interface{}
+		if err := json.Unmarshal(data, &generated); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+
+		merged := false
+		for _, entry := range existing {
+			entryMap, ok := entry.(map[string]interface{})
+			if !ok || !sameDevice(entryMap, device.Identifiers) {
+				continue
+			}
+			for key, value := range generated {
+				if key != "identifiers" {
+					entryMap[key] = value
+				}
+			}
+			merged = true
+		}
+		if !merged {
+			existing = append(existing, generated)
+		}
+	}
+	return existing, nil
+}
+
+// sameDevice reports whether an existing device entry identifies the keyboard
+func sameDevice(entry map[string]interface{}, identifiers DeviceIdentifiers) bool {
+	entryIdentifiers, ok := entry["identifiers"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	number := func(key string) int {
+		value, _ := entryIdentifiers[key].(float64)
+		return int(value)
+	}
+	isKeyboard, _ := entryIdentifiers["is_keyboard"].(bool)
+	return isKeyboard && number("vendor_id") == identifiers.VendorID && number("product_id") == identifiers.ProductID
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffOutputPath string
+	diffAgainst    string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [config_path|config_url]",
+	Short: "Show rule changes in karabiner.json",
+	Long: `Print which rules generating from the config would add (+), remove (-) or
+change (~) in the installed karabiner.json, without writing it.
+Without an argument, uses $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml
+
+--against compares the installed karabiner.json with a previous backup instead,
+and takes a backup file or "latest-backup" for the most recent backup_*.json
+next to karabiner.json.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffAgainst != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("--against compares with a backup, not with a config")
+			}
+			return diffAgainstBackup(diffOutputPath, diffAgainst)
+		}
+
+		configPath := ""
+		if len(args) > 0 {
+			configPath = args[0]
+		}
+		return diffAgainstConfig(configPath, diffOutputPath)
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVarP(&diffOutputPath, "output", "o", "", "Path to karabiner.json file")
+	diffCmd.Flags().StringVar(&diffAgainst, "against", "", "Backup file to compare with, or \"latest-backup\"")
+}
+
+// diffResult is the output of diff --json
+type diffResult struct {
+	Current string       `json:"current"`
+	Against string       `json:"against,omitempty"`
+	Config  string       `json:"config,omitempty"`
+	Changes []ruleChange `json:"changes"`
+}
+
+func diffAgainstBackup(outputPath, against string) error {
+	filePath, err := resolveOutputPath(outputPath)
+	if err != nil {
+		return err
+	}
+
+	backupPath := against
+	if against == "latest-backup" {
+		backupPath, err = latestBackup(filepath.Dir(filePath))
+		if err != nil {
+			return err
+		}
+	}
+
+	backupConfig, err := readKarabinerConfig(backupPath)
+	if err != nil {
+		return err
+	}
+	currentConfig, err := readKarabinerConfig(filePath)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(diffResult{Current: filePath, Against: backupPath, Changes: nonNilChanges(configDiff(backupConfig, currentConfig))})
+	}
+
+	fmt.Printf("Comparing %s with %s\n", filePath, backupPath)
+	if printConfigDiff(os.Stdout, backupConfig, currentConfig) == 0 {
+		fmt.Println("No changes.")
+	}
+	return nil
+}
+
+// diffAgainstConfig shows what generating from the config would change in the
+// installed karabiner.json
+func diffAgainstConfig(configPath, outputPath string) error {
+	config, err := loadDefaultConfig(configPath)
+	if err != nil {
+		return err
+	}
+	filePath, err := resolveOutputPath(outputPath)
+	if err != nil {
+		return err
+	}
+
+	// A missing karabiner.json compares as empty
+	var currentConfig KarabinerConfig
+	if _, err := os.Stat(filePath); err == nil {
+		currentConfig, err = readKarabinerConfig(filePath)
+		if err != nil {
+			return err
+		}
+	}
+	generatedConfig, err := buildKarabinerConfig(config, filePath)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(diffResult{Current: filePath, Config: config.path, Changes: nonNilChanges(configDiff(currentConfig, generatedConfig))})
+	}
+
+	fmt.Printf("Comparing %s with %s\n", filePath, config.path)
+	if printConfigDiff(os.Stdout, currentConfig, generatedConfig) == 0 {
+		fmt.Println("No changes.")
+	}
+	return nil
+}
+
+// nonNilChanges makes no changes print as an empty JSON list
+func nonNilChanges(changes []ruleChange) []ruleChange {
+	if changes == nil {
+		return []ruleChange{}
+	}
+	return changes
+}
+
+// readKarabinerConfig parses a karabiner.json file
+func readKarabinerConfig(path string) (KarabinerConfig, error) {
+	var karabinerConfig KarabinerConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return karabinerConfig, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &karabinerConfig); err != nil {
+		return karabinerConfig, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return karabinerConfig, nil
+}
+
+// latestBackup returns the most recent backup_*.json in dir
+func latestBackup(dir string) (string, error) {
+	backup, err := latestSnapshot(dir, "backup")
+	if err != nil {
+		return "", fmt.Errorf("no backups found in %s", dir)
+	}
+	return backup, nil
+}
+
+// profileRules returns the complex modification rules of every profile, the
+// descriptions prefixed with the profile name unless it's "base"
+func profileRules(karabinerConfig KarabinerConfig) []Rule {
+	var rules []Rule
+	for _, p := range karabinerConfig.Profiles {
+		if p.ComplexModifications == nil {
+			continue
+		}
+		for _, rule := range p.ComplexModifications.Rules {
+			if p.Name != "base" {
+				rule.Description = p.Name + ": " + rule.Description
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ruleKeys identifies each rule by its description and occurrence, since descriptions may repeat
+func ruleKeys(rules []Rule) []string {
+	seen := map[string]int{}
+	keys := make([]string, len(rules))
+	for i, rule := range rules {
+		keys[i] = fmt.Sprintf("%s#%d", rule.Description, seen[rule.Description])
+		seen[rule.Description]++
+	}
+	return keys
+}
+
+// ruleChange is a rule added (+), removed (-) or changed (~) between two
+// configurations
+type ruleChange struct {
+	Change          string `json:"change"`
+	Rule            string `json:"rule"`
+	OldManipulators int    `json:"old_manipulators,omitempty"`
+	NewManipulators int    `json:"new_manipulators,omitempty"`
+}
+
+// configDiff returns the rule-level changes between two configurations
+func configDiff(oldConfig, newConfig KarabinerConfig) []ruleChange {
+	oldData, _ := json.Marshal(oldConfig)
+	newData, _ := json.Marshal(newConfig)
+	if bytes.Equal(oldData, newData) {
+		return nil
+	}
+
+	oldRules := profileRules(oldConfig)
+	newRules := profileRules(newConfig)
+
+	oldByKey := map[string]Rule{}
+	for i, key := range ruleKeys(oldRules) {
+		oldByKey[key] = oldRules[i]
+	}
+	newByKey := map[string]Rule{}
+	for i, key := range ruleKeys(newRules) {
+		newByKey[key] = newRules[i]
+	}
+
+	var changes []ruleChange
+	for _, key := range ruleKeys(oldRules) {
+		if _, ok := newByKey[key]; !ok {
+			oldRule := oldByKey[key]
+			changes = append(changes, ruleChange{Change: "-", Rule: oldRule.Description, OldManipulators: len(oldRule.Manipulators)})
+		}
+	}
+	for _, key := range ruleKeys(newRules) {
+		newRule := newByKey[key]
+		oldRule, ok := oldByKey[key]
+		if !ok {
+			changes = append(changes, ruleChange{Change: "+", Rule: newRule.Description, NewManipulators: len(newRule.Manipulators)})
+			continue
+		}
+		oldRuleData, _ := json.Marshal(oldRule)
+		newRuleData, _ := json.Marshal(newRule)
+		if !bytes.Equal(oldRuleData, newRuleData) {
+			changes = append(changes, ruleChange{Change: "~", Rule: newRule.Description, OldManipulators: len(oldRule.Manipulators), NewManipulators: len(newRule.Manipulators)})
+		}
+	}
+
+	// Rules are identical, so something else in the profile changed
+	if len(changes) == 0 {
+		changes = append(changes, ruleChange{Change: "~", Rule: "profile settings"})
+	}
+	return changes
+}
+
+// diffColors are the terminal colors of the change markers
+var diffColors = map[string]string{
+	"+": "\033[32m",
+	"-": "\033[31m",
+	"~": "\033[33m",
+}
+
+// colorOutput reports whether w is a terminal that should get colors
+func colorOutput(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printConfigDiff writes a rule-level summary of the changes between two
+// configurations, colored on a terminal, and returns the number of differences
+// found
+func printConfigDiff(w io.Writer, oldConfig, newConfig KarabinerConfig) int {
+	color := colorOutput(w)
+	changes := configDiff(oldConfig, newConfig)
+	for _, change := range changes {
+		line := fmt.Sprintf("%s %s", change.Change, change.Rule)
+		if change.Change == "~" && change.Rule != "profile settings" {
+			line += fmt.Sprintf(" (%d -> %d manipulators)", change.OldManipulators, change.NewManipulators)
+		}
+		if color {
+			line = diffColors[change.Change] + line + "\033[0m"
+		}
+		fmt.Fprintln(w, line)
+	}
+	return len(changes)
+}
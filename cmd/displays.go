@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// createDisplayLayer builds a layer for moving the cursor and windows between displays
+func createDisplayLayer(displayConfig DisplayLayerConfig) (LayerConfig, error) {
+	if displayConfig.Displays < 1 || displayConfig.Displays > 9 {
+		return LayerConfig{}, fmt.Errorf("display layer supports 1-9 displays, got %d", displayConfig.Displays)
+	}
+
+	windowMover := displayConfig.WindowMover
+	if windowMover == "" {
+		windowMover = "rectangle"
+		if _, err := exec.LookPath("yabai"); err == nil {
+			windowMover = "yabai"
+		}
+	}
+
+	sub := map[string]LayerBinding{}
+
+	// 1-9 move the mouse cursor to the center of that display
+	for i := 1; i <= displayConfig.Displays; i++ {
+		sub[fmt.Sprintf("%d", i)] = LayerBinding{Type: "cursor", Val: fmt.Sprintf("%d", i)}
+	}
+
+	// n/p move the focused window to the next/previous display
+	switch windowMover {
+	case "yabai":
+		yabai := shellWord(findExecutable("yabai"))
+		sub["n"] = LayerBinding{Val: fmt.Sprintf("%s -m window --display next || %s -m window --display first", yabai, yabai)}
+		sub["p"] = LayerBinding{Val: fmt.Sprintf("%s -m window --display prev || %s -m window --display last", yabai, yabai)}
+		for i := 1; i <= displayConfig.Displays; i++ {
+			sub[fmt.Sprintf("shift+%d", i)] = LayerBinding{Val: fmt.Sprintf("%s -m window --display %d", yabai, i)}
+		}
+	case "rectangle":
+		sub["n"] = LayerBinding{Val: `open -g "rectangle://execute-action?name=next-display"`}
+		sub["p"] = LayerBinding{Val: `open -g "rectangle://execute-action?name=previous-display"`}
+	default:
+		return LayerConfig{}, fmt.Errorf("unsupported window mover: %s (supported: yabai, rectangle)", windowMover)
+	}
+
+	// m toggles mirroring between the two displayplacer layouts
+	if displayConfig.Mirror != "" && displayConfig.Extend != "" {
+		displayplacer := shellWord(findExecutable("displayplacer"))
+		sub["m"] = LayerBinding{Val: fmt.Sprintf(`if %s list | grep -q 'id:[^ ]*+'; then %s %s; else %s %s; fi`,
+			displayplacer, displayplacer, displayConfig.Extend, displayplacer, displayConfig.Mirror)}
+	}
+
+	return LayerConfig{
+		Key:  displayConfig.Key,
+		Type: "shell",
+		Sub:  sub,
+	}, nil
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorConfigPath string
+	doctorOutputPath string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the setup for common problems",
+	Long: `Run a series of checks on the config and the generated karabiner.json, and print
+how to fix the problems found.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filePath, err := resolveOutputPath(doctorOutputPath)
+		if err != nil {
+			return err
+		}
+		return runDoctor(doctorConfigPath, filePath)
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	doctorCmd.Flags().StringVarP(&doctorOutputPath, "output", "o", "", "Path to karabiner.json file")
+}
+
+// doctorCheck is one check of the doctor command; run returns a description
+// of the problem and how to fix it
+type doctorCheck struct {
+	name string
+	run  func() error
+}
+
+func doctorChecks(configPath, filePath string) []doctorCheck {
+	return []doctorCheck{
+		{"config loads", func() error {
+			config, err := loadDefaultConfig(configPath)
+			if err != nil {
+				return err
+			}
+			for _, profile := range config.profiles() {
+				if _, err := createRules(profile.config); err != nil {
+					if len(config.Profiles) > 0 {
+						return fmt.Errorf("profile %s: %w", profile.Name, err)
+					}
+					return err
+				}
+			}
+			return nil
+		}},
+		{"no bindings are unreachable", func() error {
+			config, err := loadDefaultConfig(configPath)
+			if err != nil {
+				return err
+			}
+			problems, err := lintConfig(config)
+			if err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%s, see \"karabingen lint\"", strings.Join(problems, "; "))
+			}
+			return nil
+		}},
+		{"karabiner.json runs this karabingen", func() error {
+			return checkEmbeddedExecutables(filePath)
+		}},
+		{"AppleScript bindings have their permissions", func() error {
+			karabinerConfig, err := readKarabinerConfig(filePath)
+			if err != nil {
+				return err
+			}
+			// Custom terminals only matter for the app names, so a config
+			// failing to load leaves the built-in ones
+			var terminals map[string]TerminalConfig
+			if config, err := loadDefaultConfig(configPath); err == nil {
+				terminals = config.Terminals
+			}
+			if problems := checkAppleScriptPermissions(profileRules(karabinerConfig), terminals); len(problems) > 0 {
+				return fmt.Errorf("%s", strings.Join(problems, "; "))
+			}
+			return nil
+		}},
+	}
+}
+
+// checkEmbeddedExecutables finds karabingen paths in karabiner.json that no
+// longer point at the running binary
+func checkEmbeddedExecutables(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	paths, err := embeddedExecutables(data)
+	if err != nil {
+		return err
+	}
+	executable, err := karabingenExecutable()
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for _, path := range paths {
+		if path == executable {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			stale = append(stale, path+" (missing)")
+		} else {
+			stale = append(stale, path)
+		}
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("rules run %s, run \"karabingen relink\" to use %s", strings.Join(stale, ", "), executable)
+	}
+	return nil
+}
+
+// doctorResult is the outcome of a check, as printed by doctor --json
+type doctorResult struct {
+	Check   string `json:"check"`
+	OK      bool   `json:"ok"`
+	Problem string `json:"problem,omitempty"`
+}
+
+func runDoctor(configPath, filePath string) error {
+	var results []doctorResult
+	failed := 0
+	for _, check := range doctorChecks(configPath, filePath) {
+		result := doctorResult{Check: check.name, OK: true}
+		if err := check.run(); err != nil {
+			result.OK, result.Problem = false, err.Error()
+			failed++
+		}
+		results = append(results, result)
+		if jsonOutput {
+			continue
+		}
+		if result.OK {
+			fmt.Printf("✓ %s\n", check.name)
+		} else {
+			fmt.Printf("✗ %s: %s\n", check.name, result.Problem)
+		}
+	}
+	if jsonOutput {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the checks failed", failed)
+	}
+	return nil
+}
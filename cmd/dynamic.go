@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// dynamicLayerTimeout bounds the command of a dynamic layer, so a hanging
+// command doesn't hang generation
+const dynamicLayerTimeout = 30 * time.Second
+
+// expandDynamicLayer fills the sub bindings of a "dynamic" layer from the
+// output of its command: one "key value" line per binding, of the layer's
+// sub_type ("shell" by default). Bindings in sub win over generated ones.
+// The command only runs when generating karabiner.json from a local config
+// with allow_commands set; lint, diff and the like only see the sub bindings.
+func expandDynamicLayer(config *Config, layer LayerConfig) (LayerConfig, error) {
+	if layer.Type != "dynamic" {
+		return layer, nil
+	}
+	if layer.Command == "" {
+		return LayerConfig{}, fmt.Errorf("dynamic layer %s has no command", layer.Key)
+	}
+	if !config.generating {
+		return staticLayer(layer, map[string]LayerBinding{}), nil
+	}
+	if isRemoteConfig(config.path) {
+		return LayerConfig{}, fmt.Errorf("dynamic layer %s: commands only run from a local config", layer.Key)
+	}
+	if !boolValue(config.AllowCommands, false) {
+		return LayerConfig{}, fmt.Errorf("dynamic layer %s runs %q, set allow_commands: true to allow it", layer.Key, layer.Command)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dynamicLayerTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", layer.Command)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%w: %s", err, message)
+		}
+		return LayerConfig{}, fmt.Errorf("dynamic layer %s: command failed: %w", layer.Key, err)
+	}
+
+	sub := map[string]LayerBinding{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		separator := strings.IndexAny(line, " \t")
+		if separator < 0 {
+			return LayerConfig{}, fmt.Errorf("dynamic layer %s: line %q is not \"key value\"", layer.Key, line)
+		}
+		key, val := line[:separator], strings.TrimSpace(line[separator:])
+		if _, ok := sub[key]; ok {
+			return LayerConfig{}, fmt.Errorf("dynamic layer %s: command printed key %s twice", layer.Key, key)
+		}
+		sub[key] = LayerBinding{Val: val}
+	}
+	return staticLayer(layer, sub), nil
+}
+
+// staticLayer turns a dynamic layer into a layer of its sub_type, with the
+// generated bindings under the ones of sub
+func staticLayer(layer LayerConfig, generated map[string]LayerBinding) LayerConfig {
+	for key, binding := range layer.Sub {
+		generated[key] = binding
+	}
+	layer.Sub = generated
+	layer.Type = layer.SubType
+	if layer.Type == "" {
+		layer.Type = "shell"
+	}
+	return layer
+}
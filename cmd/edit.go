@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	editOutputPath string
+	editNoBackup   bool
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit [config_path]",
+	Short: "Edit the YAML config and regenerate Karabiner configuration",
+	Long: `Open the YAML config in $EDITOR and regenerate karabiner.json once the editor exits.
+The changes are validated and previewed before being applied.
+Without a path, uses $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := ""
+		if len(args) > 0 {
+			configPath = args[0]
+		}
+		return editConfig(configPath, editOutputPath, editNoBackup)
+	},
+}
+
+func init() {
+	editCmd.Flags().StringVarP(&editOutputPath, "output", "o", "", "Path to output karabiner.json file")
+	editCmd.Flags().BoolVar(&editNoBackup, "no-backup", false, "Skip creating backup of existing karabiner.json file")
+}
+
+func editConfig(configPath, outputPath string, noBackup bool) error {
+	configPath, err := resolveConfigPath(configPath)
+	if err != nil {
+		return err
+	}
+	if isRemoteConfig(configPath) {
+		return fmt.Errorf("cannot edit remote config %s", configPath)
+	}
+
+	filePath, err := resolveOutputPath(outputPath)
+	if err != nil {
+		return err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "nvim"
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var config *Config
+	var karabinerConfig KarabinerConfig
+	for {
+		// $EDITOR may contain arguments, e.g. "code --wait"
+		cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("%s %s", editor, shellQuote(configPath)))
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("editor failed: %w", err)
+		}
+
+		config, err = loadConfig(configPath)
+		if err == nil {
+			config.setGenerating()
+			karabinerConfig, err = buildKarabinerConfig(config, filePath)
+		}
+		if err == nil {
+			break
+		}
+
+		fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+		fmt.Print("Edit again? (y/n): ")
+		confirm, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+			fmt.Println("Aborted, karabiner.json was not changed.")
+			return nil
+		}
+	}
+
+	// Preview changes against the installed configuration
+	var existingKarabinerConfig KarabinerConfig
+	if data, err := os.ReadFile(filePath); err == nil {
+		json.Unmarshal(data, &existingKarabinerConfig)
+	}
+	if printConfigDiff(os.Stdout, existingKarabinerConfig, karabinerConfig) == 0 {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	fmt.Print("Apply changes? (y/n): ")
+	confirm, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+		fmt.Println("Aborted, karabiner.json was not changed.")
+		return nil
+	}
+
+	return writeKarabinerConfig(config, karabinerConfig, filePath, noBackup)
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Karabiner-Elements keeps adding settings to karabiner.json. The sections
+// karabingen preserves from an existing file carry the fields it doesn't know
+// in Extra, so a config written by a newer Karabiner is never downgraded.
+
+// knownFields returns the JSON names of the fields of a struct type
+func knownFields(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// unmarshalWithExtra decodes data into known, a pointer to a struct, and
+// returns the fields the struct doesn't have
+func unmarshalWithExtra(data []byte, known any) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, known); err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	names := knownFields(reflect.TypeOf(known).Elem())
+	var extra map[string]json.RawMessage
+	for name, value := range fields {
+		if names[name] {
+			continue
+		}
+		if extra == nil {
+			extra = map[string]json.RawMessage{}
+		}
+		extra[name] = value
+	}
+	return extra, nil
+}
+
+// marshalWithExtra encodes known and appends the extra fields, sorted for a
+// stable output
+func marshalWithExtra(known any, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(known)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, name := range names {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(extra[name])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (c KarabinerConfig) MarshalJSON() ([]byte, error) {
+	type plain KarabinerConfig
+	return marshalWithExtra(plain(c), c.Extra)
+}
+
+func (c *KarabinerConfig) UnmarshalJSON(data []byte) error {
+	type plain KarabinerConfig
+	var known plain
+	extra, err := unmarshalWithExtra(data, &known)
+	if err != nil {
+		return err
+	}
+	*c = KarabinerConfig(known)
+	c.Extra = extra
+	return nil
+}
+
+func (g Global) MarshalJSON() ([]byte, error) {
+	type plain Global
+	return marshalWithExtra(plain(g), g.Extra)
+}
+
+func (g *Global) UnmarshalJSON(data []byte) error {
+	type plain Global
+	var known plain
+	extra, err := unmarshalWithExtra(data, &known)
+	if err != nil {
+		return err
+	}
+	*g = Global(known)
+	g.Extra = extra
+	return nil
+}
+
+func (p Profile) MarshalJSON() ([]byte, error) {
+	type plain Profile
+	return marshalWithExtra(plain(p), p.Extra)
+}
+
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type plain Profile
+	var known plain
+	extra, err := unmarshalWithExtra(data, &known)
+	if err != nil {
+		return err
+	}
+	*p = Profile(known)
+	p.Extra = extra
+	return nil
+}
+
+func (p ProfileParameters) MarshalJSON() ([]byte, error) {
+	type plain ProfileParameters
+	return marshalWithExtra(plain(p), p.Extra)
+}
+
+func (p *ProfileParameters) UnmarshalJSON(data []byte) error {
+	type plain ProfileParameters
+	var known plain
+	extra, err := unmarshalWithExtra(data, &known)
+	if err != nil {
+		return err
+	}
+	*p = ProfileParameters(known)
+	p.Extra = extra
+	return nil
+}
+
+func (k VirtualHIDKeyboard) MarshalJSON() ([]byte, error) {
+	type plain VirtualHIDKeyboard
+	return marshalWithExtra(plain(k), k.Extra)
+}
+
+func (k *VirtualHIDKeyboard) UnmarshalJSON(data []byte) error {
+	type plain VirtualHIDKeyboard
+	var known plain
+	extra, err := unmarshalWithExtra(data, &known)
+	if err != nil {
+		return err
+	}
+	*k = VirtualHIDKeyboard(known)
+	k.Extra = extra
+	return nil
+}
+
+func (m ComplexModifications) MarshalJSON() ([]byte, error) {
+	type plain ComplexModifications
+	return marshalWithExtra(plain(m), m.Extra)
+}
+
+func (m *ComplexModifications) UnmarshalJSON(data []byte) error {
+	type plain ComplexModifications
+	var known plain
+	extra, err := unmarshalWithExtra(data, &known)
+	if err != nil {
+		return err
+	}
+	*m = ComplexModifications(known)
+	m.Extra = extra
+	return nil
+}
+
+func (p Parameters) MarshalJSON() ([]byte, error) {
+	type plain Parameters
+	return marshalWithExtra(plain(p), p.Extra)
+}
+
+func (p *Parameters) UnmarshalJSON(data []byte) error {
+	type plain Parameters
+	var known plain
+	extra, err := unmarshalWithExtra(data, &known)
+	if err != nil {
+		return err
+	}
+	*p = Parameters(known)
+	p.Extra = extra
+	return nil
+}
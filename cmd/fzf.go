@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// fzfPicker runs fzf for the interactive pickers
+type fzfPicker struct {
+	path    string
+	options []string // extra fzf options, e.g. --layout=reverse or --preview
+}
+
+// loadFzfPicker resolves fzf from the flags and the fzf_options and paths.fzf
+// config settings. Flags win: the fzf path flag over the config, and option
+// flags come last since fzf uses the last occurrence of an option.
+func loadFzfPicker(configPath, path string, options []string) (fzfPicker, error) {
+	picker := fzfPicker{path: path}
+	// The config is optional for pickers
+	config, err := loadDefaultConfig(configPath)
+	if err != nil && !errors.Is(err, errNoConfig) {
+		return fzfPicker{}, err
+	}
+	if config != nil {
+		if picker.path == "" {
+			picker.path = config.toolPath("fzf")
+		}
+		picker.options = append(picker.options, config.FzfOptions...)
+	}
+	if picker.path == "" {
+		picker.path = findExecutable("fzf")
+	}
+	picker.options = append(picker.options, options...)
+	return picker, nil
+}
+
+// pick shows the input lines in fzf and returns the selected line, or "" when cancelled
+func (p fzfPicker) pick(input string, args ...string) string {
+	cmd := exec.Command(p.path, append(args, p.options...)...)
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		// User probably cancelled (Ctrl+C or ESC)
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// pickWithKeys is pick with fzf --expect: it also returns which of keys accepted
+// the selection, "" for enter
+func (p fzfPicker) pickWithKeys(input string, keys []string, args ...string) (string, string) {
+	args = append(args, "--expect="+strings.Join(keys, ","))
+	cmd := exec.Command(p.path, append(args, p.options...)...)
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", ""
+	}
+
+	// The first line is the key pressed, the second the selection
+	lines := strings.SplitN(strings.TrimRight(string(output), "\n"), "\n", 2)
+	if len(lines) < 2 {
+		return "", ""
+	}
+	return lines[0], strings.TrimSpace(lines[1])
+}
@@ -6,62 +6,273 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
-var (
-	outputPath string
-	noBackup   bool
-)
+// generateOptions are the flags of generate
+type generateOptions struct {
+	outputPath       string
+	noBackup         bool
+	notify           bool
+	force            bool
+	profile          bool
+	dryRun           bool
+	checkPermissions bool
+	reload           bool
+}
+
+var generateOpts generateOptions
 
 var generateCmd = &cobra.Command{
-	Use:   "generate <config_path>",
+	Use:   "generate [config_path|config_url]",
 	Short: "Generate Karabiner configuration from YAML",
 	Long: `Generate karabiner.json from a simplified YAML configuration file.
-By default, writes to ~/.config/karabiner/karabiner.json`,
-	Args: cobra.ExactArgs(1),
+By default, writes to ~/.config/karabiner/karabiner.json
+
+--dry-run prints the generated karabiner.json to stdout instead, without touching
+the filesystem, e.g. to pipe it to jq or review it in CI.
+
+The config may be an http(s) URL, optionally pinned with a "#sha256=<hex>" fragment.
+Without an argument, uses $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		configPath := args[0]
-		return generateKarabinerConfig(configPath, outputPath, noBackup)
+		configPath := ""
+		if len(args) > 0 {
+			configPath = args[0]
+		}
+		return generateKarabinerConfig(configPath, generateOpts)
 	},
 }
 
 func init() {
-	generateCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Path to output karabiner.json file")
-	generateCmd.Flags().BoolVar(&noBackup, "no-backup", false, "Skip creating backup of existing karabiner.json file")
+	generateCmd.Flags().StringVarP(&generateOpts.outputPath, "output", "o", "", "Path to output karabiner.json file")
+	generateCmd.Flags().BoolVar(&generateOpts.noBackup, "no-backup", false, "Skip creating backup of existing karabiner.json file")
+	generateCmd.Flags().BoolVar(&generateOpts.notify, "notify", false, "Post a macOS notification when the configuration changed or generation failed")
+	generateCmd.Flags().BoolVar(&generateOpts.force, "force", false, "Write rules exceeding max_manipulators anyway")
+	generateCmd.Flags().BoolVar(&generateOpts.profile, "profile-gen", false, "Report the time spent in each generation step and the size of karabiner.json")
+	generateCmd.Flags().BoolVar(&generateOpts.dryRun, "dry-run", false, "Print the generated karabiner.json to stdout instead of writing it")
+	generateCmd.Flags().BoolVar(&generateOpts.checkPermissions, "check-permissions", false, "Warn when AppleScript bindings lack the Automation or Accessibility permission")
+	generateCmd.Flags().BoolVar(&generateOpts.reload, "reload", false, "Restart karabiner_console_user_server so Karabiner-Elements loads the new configuration")
+	generateCmd.MarkFlagsMutuallyExclusive("dry-run", "profile-gen")
+	generateCmd.MarkFlagsMutuallyExclusive("dry-run", "reload")
 }
 
-func generateKarabinerConfig(configPath, outputPath string, noBackup bool) error {
+func generateKarabinerConfig(configPath string, opts generateOptions) (err error) {
+	notify := opts.notify
+	defer func() {
+		if err != nil && notify {
+			postNotification(fmt.Sprintf("Generation failed: %v", err))
+		}
+	}()
+
 	// Load and parse config
-	config, err := loadConfig(configPath)
+	start := time.Now()
+	config, err := loadDefaultConfig(configPath)
+	if err != nil {
+		return err
+	}
+	loaded := time.Now()
+	notify = notify || boolValue(config.Notify, false)
+	if !opts.dryRun {
+		config.setGenerating()
+	}
+
+	filePath, err := resolveOutputPath(opts.outputPath)
 	if err != nil {
 		return err
 	}
 
-	// Determine output path
-	var filePath string
-	if outputPath != "" {
-		filePath = outputPath
-	} else {
-		home, err := os.UserHomeDir()
+	karabinerConfig, err := buildKarabinerConfig(config, filePath)
+	if err != nil {
+		return err
+	}
+	built := time.Now()
+
+	if err := checkManipulatorLimit(config, karabinerConfig); err != nil {
+		if !opts.force {
+			return fmt.Errorf("%w; use --force to write it anyway", err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	if opts.dryRun {
+		data, err := json.MarshalIndent(karabinerConfig, "", "  ")
 		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		_, err = fmt.Println(string(data))
+		return err
+	}
+
+	// Count changes before the existing file is overwritten
+	existingKarabinerConfig, _ := readKarabinerConfig(filePath)
+	changes := printConfigDiff(io.Discard, existingKarabinerConfig, karabinerConfig)
+
+	if err := writeKarabinerConfig(config, karabinerConfig, filePath, opts.noBackup); err != nil {
+		return err
+	}
+
+	if opts.profile {
+		if err := printGenerationProfile(karabinerConfig, loaded.Sub(start), built.Sub(loaded)); err != nil {
+			return err
+		}
+	}
+
+	if opts.reload {
+		if err := reloadKarabiner(config, karabinerConfig); err != nil {
+			return err
+		}
+	}
+
+	if opts.checkPermissions {
+		for _, problem := range checkAppleScriptPermissions(profileRules(karabinerConfig), config.Terminals) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
 		}
-		filePath = filepath.Join(home, ".config", "karabiner", "karabiner.json")
 	}
 
+	if notify && changes > 0 {
+		postNotification(fmt.Sprintf("%d rules regenerated", len(profileRules(karabinerConfig))))
+	}
+	return nil
+}
+
+// printGenerationProfile reports where generation spends its time, for configs
+// that produce thousands of manipulators
+func printGenerationProfile(karabinerConfig KarabinerConfig, loading, generating time.Duration) error {
+	start := time.Now()
+	data, err := json.MarshalIndent(karabinerConfig, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	marshaling := time.Since(start)
+
+	rules := profileRules(karabinerConfig)
+	manipulators := 0
+	for _, rule := range rules {
+		manipulators += len(rule.Manipulators)
+	}
+
+	fmt.Printf("Config loading:   %v\n", loading.Round(time.Microsecond))
+	fmt.Printf("Rule generation:  %v\n", generating.Round(time.Microsecond))
+	fmt.Printf("JSON marshaling:  %v\n", marshaling.Round(time.Microsecond))
+	fmt.Printf("Rules:            %d (%d manipulators)\n", len(rules), manipulators)
+	fmt.Printf("File size:        %s\n", formatSize(len(data)))
+	return nil
+}
+
+// formatSize renders a byte count, e.g. "1.2 MB"
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// checkManipulatorLimit catches configuration mistakes, like a tmux_jump with
+// all_letters on every key, that bloat karabiner.json and slow Karabiner down
+func checkManipulatorLimit(config *Config, karabinerConfig KarabinerConfig) error {
+	if config.MaxManipulators <= 0 {
+		return nil
+	}
+
+	var tooBig []string
+	for _, rule := range profileRules(karabinerConfig) {
+		if len(rule.Manipulators) > config.MaxManipulators {
+			tooBig = append(tooBig, fmt.Sprintf("%q (%d)", rule.Description, len(rule.Manipulators)))
+		}
+	}
+	if len(tooBig) == 0 {
+		return nil
+	}
+	return fmt.Errorf("rules with more than max_manipulators (%d) manipulators: %s", config.MaxManipulators, strings.Join(tooBig, ", "))
+}
+
+// resolveOutputPath returns the karabiner.json path, defaulting to ~/.config/karabiner/karabiner.json
+func resolveOutputPath(outputPath string) (string, error) {
+	if outputPath != "" {
+		return outputPath, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "karabiner", "karabiner.json"), nil
+}
+
+// buildKarabinerConfig generates the Karabiner configuration, preserving
+// settings of the existing file at filePath
+func buildKarabinerConfig(config *Config, filePath string) (KarabinerConfig, error) {
+	config.setOutputPath(filePath)
+
 	// Load existing karabiner config to preserve devices and other settings
 	var existingKarabinerConfig KarabinerConfig
 	if data, err := os.ReadFile(filePath); err == nil {
 		json.Unmarshal(data, &existingKarabinerConfig)
 	}
 
-	// Create profile
+	profiles := config.profiles()
+
+	// The profile selected in Karabiner stays selected
+	selected := profiles[0].Name
+	for _, p := range existingKarabinerConfig.Profiles {
+		if !p.Selected {
+			continue
+		}
+		for _, profile := range profiles {
+			if profile.Name == p.Name {
+				selected = p.Name
+			}
+		}
+	}
+
+	karabinerConfig := KarabinerConfig{
+		Global: Global{
+			ShowProfileNameInMenuBar: true,
+		},
+	}
+	for _, profileConfig := range profiles {
+		var existingProfile Profile
+		for _, p := range existingKarabinerConfig.Profiles {
+			if p.Name == profileConfig.Name {
+				existingProfile = p
+				break
+			}
+		}
+		profile, err := buildProfile(profileConfig.config, profileConfig.Name, existingProfile)
+		if err != nil {
+			if len(config.Profiles) > 0 {
+				return KarabinerConfig{}, fmt.Errorf("profile %s: %w", profileConfig.Name, err)
+			}
+			return KarabinerConfig{}, err
+		}
+		profile.Selected = profileConfig.Name == selected
+		karabinerConfig.Profiles = append(karabinerConfig.Profiles, profile)
+	}
+
+	// Preserve existing global settings if they exist
+	if existingKarabinerConfig.Global.ShowProfileNameInMenuBar {
+		karabinerConfig.Global = existingKarabinerConfig.Global
+	}
+	karabinerConfig.Global.Extra = existingKarabinerConfig.Global.Extra
+	karabinerConfig.Extra = existingKarabinerConfig.Extra
+
+	return karabinerConfig, nil
+}
+
+// buildProfile generates a Karabiner profile, preserving the devices and
+// unknown settings of the existing profile of that name
+func buildProfile(config *Config, name string, existingProfile Profile) (Profile, error) {
 	profile := Profile{
-		Name:     "base",
-		Selected: true,
+		Name: name,
 		VirtualHIDKeyboard: &VirtualHIDKeyboard{
 			KeyboardTypeV2: "iso",
 		},
@@ -71,46 +282,137 @@ func generateKarabinerConfig(configPath, outputPath string, noBackup bool) error
 		},
 	}
 
-	// Preserve existing devices configuration if it exists
-	for _, p := range existingKarabinerConfig.Profiles {
-		if p.Name == "base" && p.Devices != nil {
-			profile.Devices = p.Devices
-			break
+	// Preserve existing devices configuration and unknown settings if they exist
+	profile.Devices = existingProfile.Devices
+	profile.Extra = existingProfile.Extra
+	if existingProfile.VirtualHIDKeyboard != nil {
+		profile.VirtualHIDKeyboard.Extra = existingProfile.VirtualHIDKeyboard.Extra
+	}
+	if existingProfile.ComplexModifications != nil {
+		profile.ComplexModifications.Extra = existingProfile.ComplexModifications.Extra
+	}
+
+	// Per-device settings from the config, merged into the preserved devices
+	devices, err := createDevices(config)
+	if err != nil {
+		return Profile{}, err
+	}
+	profile.Devices, err = mergeDevices(profile.Devices, devices)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	// Parameters start from the existing ones, the config only overrides the
+	// values it sets
+	if existingProfile.Parameters != nil {
+		parameters := *existingProfile.Parameters
+		profile.Parameters = &parameters
+	}
+	if config.Parameters.DelayBeforeOpenDeviceMs > 0 {
+		if profile.Parameters == nil {
+			profile.Parameters = &ProfileParameters{}
 		}
+		profile.Parameters.DelayMillisecondsBeforeOpenDevice = config.Parameters.DelayBeforeOpenDeviceMs
+	}
+	parameters := Parameters{}
+	if existingProfile.ComplexModifications != nil && existingProfile.ComplexModifications.Parameters != nil {
+		parameters = *existingProfile.ComplexModifications.Parameters
+	}
+	if parameters = applyParameters(parameters, config.Parameters); !parameters.empty() {
+		profile.ComplexModifications.Parameters = &parameters
 	}
 
-	if config.FixCC {
+	if boolValue(config.FixCC, false) {
 		profile.SimpleModifications = append(profile.SimpleModifications, SimpleModification{
 			From: KeyCode{KeyCode: "grave_accent_and_tilde"},
 			To:   []KeyCode{{KeyCode: "non_us_backslash"}},
 		})
 	}
 
+	// Modifier swaps of every keyboard, sorted for a stable output
+	presetKeys, err := resolveModifierPresets(config.ModifierPresets)
+	if err != nil {
+		return Profile{}, err
+	}
+	if _, ok := presetKeys[config.Hyperkey]; ok {
+		return Profile{}, fmt.Errorf("modifier_presets remap %s, which is the hyper key", config.Hyperkey)
+	}
+	froms := make([]string, 0, len(presetKeys))
+	for from := range presetKeys {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		profile.SimpleModifications = append(profile.SimpleModifications, SimpleModification{
+			From: KeyCode{KeyCode: from},
+			To:   []KeyCode{{KeyCode: presetKeys[from]}},
+		})
+	}
+
 	// Generate complex modification rules
-	rules := []Rule{}
+	presetRules, err := createRules(config)
+	if err != nil {
+		return Profile{}, err
+	}
+	rules := make([]Rule, len(presetRules))
+	for i, generated := range presetRules {
+		rules[i] = generated.rule
+	}
+
+	// Set rules in profile
+	profile.ComplexModifications.Rules = rules
+
+	return profile, nil
+}
+
+// presetRule is a generated rule along with the config section (preset) it comes from
+type presetRule struct {
+	preset string
+	rule   Rule
+}
+
+// createRules generates the complex modification rules of the config
+func createRules(config *Config) ([]presetRule, error) {
+	rules := []presetRule{}
+
+	// Custom rules come first, so they win over the presets
+	for _, custom := range config.CustomRules {
+		customRule, err := createCustomRule(config, custom)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, presetRule{"custom_rules", customRule})
+	}
 
 	// Add HHKB mode if requested
-	if config.UseHHKB {
-		rules = append(rules, createHHKBModeRule())
-		// If hyperkey is not caps_lock, add hyperkey rule
-		if config.Hyperkey != "caps_lock" {
-			rules = append(rules, createHyperKeyRule(config.Hyperkey))
+	if boolValue(config.UseHHKB, false) {
+		rules = append(rules, presetRule{"use_hhkb", createHHKBModeRule(config.CapsLock)})
+	}
+	// Hyperkey is empty in HHKB mode without hhkb.hyper_on
+	if config.Hyperkey != "" {
+		hyperRule, err := createHyperKeyRule(config.Hyperkey, config.Hyper, config.CapsLock)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		rules = append(rules, createHyperKeyRule(config.Hyperkey))
+		rules = append(rules, presetRule{"hyperkey", hyperRule})
 	}
 
 	// Apply optional rules based on config
 	optionalRules := []struct {
+		preset  string
 		enabled bool
 		rule    func() Rule
 	}{
-		{config.DisableLeftCtrl, createDisableLeftCtrlRule},
-		{config.DisableCommandTab, createDisableCommandTabRule},
-		{config.SwitchSafariTabsHL, createSwitchTabsRule},
-		{config.FixG502.Enable, func() Rule {
+		{"disable_left_ctrl", boolValue(config.DisableLeftCtrl, false), createDisableLeftCtrlRule},
+		{"disable_command_tab", boolValue(config.DisableCommandTab, false), createDisableCommandTabRule},
+		{"switch_safari_tabs_hl", boolValue(config.SwitchSafariTabsHL, false), createSwitchTabsRule},
+		{"both_shifts_caps_lock", boolValue(config.BothShiftsCapsLock, false), createBothShiftsCapsLockRule},
+		{"function_keys_toggle", boolValue(config.FunctionKeysToggle.Enable, false), func() Rule {
+			return createFunctionKeysToggleRule(config.FunctionKeysToggle)
+		}},
+		{"fix_g502", boolValue(config.FixG502.Enable, false), func() Rule {
 			return createFixG502Rule(
-				config.FixG502.SafariOnly,
+				boolValue(config.FixG502.SafariOnly, true),
 				config.FixG502.BackButton,
 				config.FixG502.ForwardButton,
 			)
@@ -119,63 +421,447 @@ func generateKarabinerConfig(configPath, outputPath string, noBackup bool) error
 
 	for _, opt := range optionalRules {
 		if opt.enabled {
-			rules = append(rules, opt.rule())
+			rules = append(rules, presetRule{opt.preset, opt.rule()})
+		}
+	}
+
+	// Window cycling of the frontmost app
+	if boolValue(config.CycleWindows.Enable, false) {
+		cycleWindowsRule, err := createCycleWindowsRule(config, config.CycleWindows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, presetRule{"cycle_windows", cycleWindowsRule})
+	}
+
+	// Double modifier shortcuts
+	for _, doubleModifier := range config.DoubleModifiers {
+		doubleModifierRule, err := createDoubleModifierRule(config, doubleModifier)
+		if err != nil {
+			return nil, err
 		}
+		rules = append(rules, presetRule{"double_modifiers", doubleModifierRule})
+	}
+
+	// Password manager quick access
+	if boolValue(config.PasswordManager.Enable, false) {
+		passwordManagerRule, err := createPasswordManagerRule(config.PasswordManager)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, presetRule{"password_manager", passwordManagerRule})
+	}
+
+	// Regenerate from the keyboard
+	if boolValue(config.Regenerate.Enable, false) {
+		regenerateRule, err := createRegenerateRule(config)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, presetRule{"regenerate", regenerateRule})
+	}
+
+	// Hold bindings
+	for _, holdBinding := range config.HoldBindings {
+		holdRule, err := createHoldBindingRule(config, holdBinding)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, presetRule{"hold_bindings", holdRule})
+	}
+
+	// Bare modifier key actions
+	for _, modifierKey := range config.ModifierKeys {
+		modifierKeyRule, err := createModifierKeyRule(config, modifierKey)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, presetRule{"modifier_keys", modifierKeyRule})
+	}
+
+	// Leader key sequences
+	if config.Leader.Key != "" {
+		leaderRule, err := createLeaderRule(config, config.Leader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create leader rule: %w", err)
+		}
+		rules = append(rules, presetRule{"leader", leaderRule})
 	}
 
 	// Tmux jump
-	if config.TmuxJump.Enable {
+	if boolValue(config.TmuxJump.Enable, false) {
 		tmuxRule, err := createTmuxJumpRule(config)
 		if err != nil {
-			return fmt.Errorf("failed to create tmux jump rule: %w", err)
+			return nil, fmt.Errorf("failed to create tmux jump rule: %w", err)
 		}
-		rules = append(rules, tmuxRule)
+		rules = append(rules, presetRule{"tmux_jump", tmuxRule})
 	}
 
-	// Option keybindings
-	for key, binding := range config.Keybindings.Option {
-		rules = append(rules, createOptionKeybindingRule(key, binding))
+	// Option keybindings, sorted for a stable output
+	optionKeys := make([]string, 0, len(config.Keybindings.Option))
+	for key := range config.Keybindings.Option {
+		optionKeys = append(optionKeys, key)
+	}
+	sort.Strings(optionKeys)
+	for _, key := range optionKeys {
+		optionRule, err := createOptionKeybindingRule(config, key, config.Keybindings.Option[key])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, presetRule{"option", optionRule})
 	}
 
 	// HJKL arrow keys
-	rules = append(rules, createHJKLRule())
+	rules = append(rules, presetRule{"hjkl", exceptApps(createHJKLRule(config.HJKL.Modifier, config.optionalModifiers(nil, config.HJKL.Optional)), config.HJKL.ExceptApps)})
 
 	// Layer rules
-	rules = append(rules, createLayerRules(config.Keybindings.Layers)...)
+	layers := make([]LayerConfig, len(config.Keybindings.Layers))
+	layerPresets := make([]string, len(layers))
+	for i, layer := range config.Keybindings.Layers {
+		layer, err := expandDynamicLayer(config, layer)
+		if err != nil {
+			return nil, err
+		}
+		layers[i] = layer
+		layerPresets[i] = "layers"
+	}
+	if boolValue(config.SymbolsLayer.Enable, false) {
+		layers = append(layers, createSymbolsLayer(config.SymbolsLayer))
+		layerPresets = append(layerPresets, "symbols_layer")
+	}
+	if boolValue(config.WindowLayer.Enable, false) {
+		windowLayer, err := createWindowLayer(config.WindowLayer)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, windowLayer)
+		layerPresets = append(layerPresets, "window_layer")
+	}
+	if boolValue(config.SpacesLayer.Enable, false) {
+		spacesLayer, err := createSpacesLayer(config.SpacesLayer)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, spacesLayer)
+		layerPresets = append(layerPresets, "spaces_layer")
+	}
+	if boolValue(config.DisplayLayer.Enable, false) {
+		displayLayer, err := createDisplayLayer(config.DisplayLayer)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, displayLayer)
+		layerPresets = append(layerPresets, "display_layer")
+	}
+	if boolValue(config.CharsLayer.Enable, false) {
+		layers = append(layers, createCharsLayer(config.CharsLayer))
+		layerPresets = append(layerPresets, "chars_layer")
+	}
+	if boolValue(config.UtilityLayer.Enable, false) {
+		utilityLayer, err := createUtilityLayer(config.UtilityLayer)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, utilityLayer)
+		layerPresets = append(layerPresets, "utility_layer")
+	}
+	if boolValue(config.MediaLayer.Enable, false) {
+		mediaLayer, err := createMediaLayer(config.MediaLayer)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, mediaLayer)
+		layerPresets = append(layerPresets, "media_layer")
+	}
+	if boolValue(config.SSHLayer.Enable, false) {
+		sshLayer, err := createSSHLayer(config, config.SSHLayer)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, sshLayer)
+		layerPresets = append(layerPresets, "ssh_layer")
+	}
+	if boolValue(config.VolumeLayer.Enable, false) {
+		layers = append(layers, createVolumeLayer(config.VolumeLayer))
+		layerPresets = append(layerPresets, "volume_layer")
+	}
 
-	// Set rules in profile
-	profile.ComplexModifications.Rules = rules
+	layerRules, err := createLayerRules(config, layers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create layer rules: %w", err)
+	}
 
-	// Create final Karabiner config
-	karabinerConfig := KarabinerConfig{
-		Global: Global{
-			ShowProfileNameInMenuBar: true,
-		},
-		Profiles: []Profile{profile},
+	// createLayerRules returns one rule per layer
+	for i, layerRule := range layerRules {
+		if layers[i].Schedule != "" {
+			layerRule, err = scheduleRule(config, layerRule, layers[i].Schedule)
+			if err != nil {
+				return nil, fmt.Errorf("layer %s: %w", layers[i].Key, err)
+			}
+		}
+		rules = append(rules, presetRule{layerPresets[i], layerRule})
 	}
 
-	// Preserve existing global settings if they exist
-	if existingKarabinerConfig.Global.ShowProfileNameInMenuBar {
-		karabinerConfig.Global = existingKarabinerConfig.Global
+	// Real hyper modifiers leave the hyper variable unset, so nothing may wait for it
+	if boolValue(config.Hyper.Modifiers, false) {
+		for _, generated := range rules {
+			for _, m := range generated.rule.Manipulators {
+				if slices.ContainsFunc(m.Conditions, func(c Condition) bool { return c.Type == "variable_if" && c.Name == "hyper" }) {
+					return nil, fmt.Errorf("%s needs the hyper variable, which hyper.modifiers doesn't set; disable one of them", generated.preset)
+				}
+			}
+		}
 	}
 
+	// Releasing the hyper key also releases its hold layers, in case a layer
+	// key's key up got lost
+	for i := range rules {
+		if rules[i].preset == "hyperkey" {
+			rules[i].rule = releaseHoldLayers(rules[i].rule, layers)
+		}
+	}
+
+	// Per-rule parameter overrides, keyed by preset name
+	overridePresets := make([]string, 0, len(config.RuleParameters))
+	for preset := range config.RuleParameters {
+		overridePresets = append(overridePresets, preset)
+	}
+	sort.Strings(overridePresets)
+	for _, preset := range overridePresets {
+		override := config.RuleParameters[preset]
+		if override.DelayBeforeOpenDeviceMs != 0 {
+			return nil, fmt.Errorf("rule_parameters %s: delay_before_open_device_ms is profile-wide, set it in parameters", preset)
+		}
+		found := false
+		for i := range rules {
+			if rules[i].preset == preset {
+				rules[i].rule = overrideParameters(rules[i].rule, override)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("rule_parameters %s: preset generates no rules", preset)
+		}
+	}
+
+	// Per-rule schedules, keyed by preset name
+	schedulePresets := make([]string, 0, len(config.RuleSchedules))
+	for preset := range config.RuleSchedules {
+		schedulePresets = append(schedulePresets, preset)
+	}
+	sort.Strings(schedulePresets)
+	for _, preset := range schedulePresets {
+		found := false
+		for i := range rules {
+			if rules[i].preset == preset {
+				scheduled, err := scheduleRule(config, rules[i].rule, config.RuleSchedules[preset])
+				if err != nil {
+					return nil, fmt.Errorf("rule_schedules %s: %w", preset, err)
+				}
+				rules[i].rule = scheduled
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("rule_schedules %s: preset generates no rules", preset)
+		}
+	}
+
+	// Per-rule app exceptions, keyed by preset name
+	exceptPresets := make([]string, 0, len(config.RuleExceptApps))
+	for preset := range config.RuleExceptApps {
+		exceptPresets = append(exceptPresets, preset)
+	}
+	sort.Strings(exceptPresets)
+	for _, preset := range exceptPresets {
+		found := false
+		for i := range rules {
+			if rules[i].preset == preset {
+				rules[i].rule = exceptApps(rules[i].rule, config.RuleExceptApps[preset])
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("rule_except_apps %s: preset generates no rules", preset)
+		}
+	}
+
+	// Per-rule keyboards, keyed by preset name: device_if keeps the rules to
+	// the devices, device_unless keeps them off
+	for _, restriction := range []struct {
+		option        string
+		conditionType string
+		devices       map[string][]string
+	}{
+		{"rule_devices", "device_if", config.RuleDevices},
+		{"rule_except_devices", "device_unless", config.RuleExceptDevices},
+	} {
+		devicePresets := make([]string, 0, len(restriction.devices))
+		for preset := range restriction.devices {
+			devicePresets = append(devicePresets, preset)
+		}
+		sort.Strings(devicePresets)
+		for _, preset := range devicePresets {
+			identifiers, err := deviceIdentifiers(config, restriction.devices[preset])
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", restriction.option, preset, err)
+			}
+			found := false
+			for i := range rules {
+				if rules[i].preset == preset {
+					rules[i].rule = restrictDevices(rules[i].rule, restriction.conditionType, identifiers)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%s %s: preset generates no rules", restriction.option, preset)
+			}
+		}
+	}
+
+	if len(config.RuleGroups) > 0 {
+		var err error
+		rules, err = groupRules(rules, config.RuleGroups)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Expand either_<modifier> into left and right variants and tag generated rules
+	for i := range rules {
+		rules[i].rule = expandEitherModifiers(rules[i].rule)
+		if config.DescriptionTag != "" {
+			rules[i].rule.Description = config.DescriptionTag + " " + rules[i].rule.Description
+		}
+	}
+
+	return rules, nil
+}
+
+// groupRules merges the rules of each group into one rule named after the
+// group. A group takes the place of the first rule it merges, so the rules
+// keep their order, with the custom rules first.
+func groupRules(rules []presetRule, groups []RuleGroupConfig) ([]presetRule, error) {
+	generated := map[string]bool{}
+	for _, r := range rules {
+		generated[r.preset] = true
+	}
+
+	groupOf := map[string]string{}
+	enabled := map[string]bool{}
+	for _, group := range groups {
+		if group.Name == "" {
+			return nil, fmt.Errorf("rule_groups: group without a name")
+		}
+		for _, preset := range group.Presets {
+			if other, ok := groupOf[preset]; ok {
+				return nil, fmt.Errorf("rule_groups: preset %s is in both %s and %s", preset, other, group.Name)
+			}
+			if !generated[preset] {
+				return nil, fmt.Errorf("rule_groups %s: preset %s generates no rules", group.Name, preset)
+			}
+			groupOf[preset] = group.Name
+		}
+		enabled[group.Name] = boolValue(group.Enable, true)
+	}
+
+	grouped := []presetRule{}
+	position := map[string]int{}
+	for _, r := range rules {
+		name, ok := groupOf[r.preset]
+		if !ok {
+			grouped = append(grouped, r)
+			continue
+		}
+		if !enabled[name] {
+			continue
+		}
+		i, ok := position[name]
+		if !ok {
+			i = len(grouped)
+			position[name] = i
+			grouped = append(grouped, presetRule{name, Rule{Description: name}})
+		}
+		// Manipulators keep the rule description for the Karabiner UI
+		for _, m := range r.rule.Manipulators {
+			if m.Description == "" {
+				m.Description = r.rule.Description
+			}
+			grouped[i].rule.Manipulators = append(grouped[i].rule.Manipulators, m)
+		}
+	}
+	return grouped, nil
+}
+
+// overrideParameters sets the non-zero parameters of override on every manipulator of rule
+func overrideParameters(rule Rule, override ParametersConfig) Rule {
+	manipulators := make([]Manipulator, len(rule.Manipulators))
+	for i, m := range rule.Manipulators {
+		parameters := Parameters{}
+		if m.Parameters != nil {
+			parameters = *m.Parameters
+		}
+		if parameters = applyParameters(parameters, override); !parameters.empty() {
+			m.Parameters = &parameters
+		}
+		manipulators[i] = m
+	}
+	rule.Manipulators = manipulators
+	return rule
+}
+
+// applyParameters sets the non-zero values of config on parameters
+func applyParameters(parameters Parameters, config ParametersConfig) Parameters {
+	if config.ToIfAloneTimeoutMs != 0 {
+		parameters.BasicToIfAloneTimeoutMilliseconds = config.ToIfAloneTimeoutMs
+	}
+	if config.ToIfHeldDownThresholdMs != 0 {
+		parameters.BasicToIfHeldDownThresholdMilliseconds = config.ToIfHeldDownThresholdMs
+	}
+	if config.ToDelayedActionDelayMs != 0 {
+		parameters.BasicToDelayedActionDelayMilliseconds = config.ToDelayedActionDelayMs
+	}
+	if config.SimultaneousThresholdMs != 0 {
+		parameters.BasicSimultaneousThresholdMilliseconds = config.SimultaneousThresholdMs
+	}
+	return parameters
+}
+
+// empty reports whether no parameter is set
+func (p Parameters) empty() bool {
+	return p.BasicToIfAloneTimeoutMilliseconds == 0 && p.BasicToDelayedActionDelayMilliseconds == 0 &&
+		p.BasicToIfHeldDownThresholdMilliseconds == 0 && p.BasicSimultaneousThresholdMilliseconds == 0 &&
+		len(p.Extra) == 0
+}
+
+// backupKarabinerConfig copies an existing karabiner.json to a timestamped
+// backup_*.json next to it
+func backupKarabinerConfig(filePath string) {
+	if _, err := os.Stat(filePath); err != nil {
+		return
+	}
+	backupPath := newSnapshotPath(filepath.Dir(filePath), "backup")
+	if err := copyFile(filePath, backupPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to create backup: %v\n", err)
+	} else {
+		fmt.Printf("Backup created: %s\n", backupPath)
+	}
+}
+
+// writeKarabinerConfig writes the configuration to filePath, backing up the existing file
+// or committing it when git_commit is enabled
+func writeKarabinerConfig(config *Config, karabinerConfig KarabinerConfig, filePath string, noBackup bool) error {
 	// Ensure output directory exists
-	if err = os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// Git history replaces backup files
+	useGit := boolValue(config.GitCommit, false) && isGitRepo(filepath.Dir(filePath))
+
 	// Create backup if file exists and backup is not disabled
-	if !noBackup {
-		if _, err := os.Stat(filePath); err == nil {
-			timestamp := time.Now().Format("20060102_150405")
-			backupName := fmt.Sprintf("backup_%s.json", timestamp)
-			backupPath := filepath.Join(filepath.Dir(filePath), backupName)
-			if err := copyFile(filePath, backupPath); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to create backup: %v\n", err)
-			} else {
-				fmt.Printf("Backup created: %s\n", backupPath)
-			}
-		}
+	if !noBackup && !useGit {
+		backupKarabinerConfig(filePath)
 	}
 
 	// Write output file
@@ -189,6 +875,13 @@ func generateKarabinerConfig(configPath, outputPath string, noBackup bool) error
 	}
 
 	fmt.Printf("Configuration written to: %s\n", filePath)
+	clearRedo(filepath.Dir(filePath))
+
+	if useGit {
+		if err := commitKarabinerConfig(config, filePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit karabiner.json: %v\n", err)
+		}
+	}
 	return nil
 }
 
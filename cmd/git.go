@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// isGitRepo reports whether dir is inside a git work tree
+func isGitRepo(dir string) bool {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// commitKarabinerConfig commits the regenerated karabiner.json, recording the
+// karabingen version and the hash of the config it was generated from
+func commitKarabinerConfig(config *Config, filePath string) error {
+	dir := filepath.Dir(filePath)
+	name := filepath.Base(filePath)
+
+	if out, err := exec.Command("git", "-C", dir, "add", "--", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("git add failed: %s", strings.TrimSpace(string(out)))
+	}
+
+	// Nothing staged means the output did not change
+	if err := exec.Command("git", "-C", dir, "diff", "--cached", "--quiet", "--", name).Run(); err == nil {
+		return nil
+	}
+
+	message := fmt.Sprintf("karabingen %s: regenerate %s\n\nConfig: %s\nConfig sha256: %s", rootCmd.Version, name, config.path, config.hash)
+	if out, err := exec.Command("git", "-C", dir, "commit", "-q", "-m", message, "--", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("git commit failed: %s", strings.TrimSpace(string(out)))
+	}
+
+	fmt.Printf("Committed %s to git\n", name)
+	return nil
+}
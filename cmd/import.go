@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	importProfile string
+	importOutPath string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <karabiner.json>",
+	Short: "Convert an existing karabiner.json into a config",
+	Long: `Write a config reproducing the rules of an existing karabiner.json, e.g. to adopt
+karabingen on a machine with a hand-tuned setup. Rules matching a preset become
+its config (hyper key, layers, option bindings, ...); every other rule is kept
+as is in custom_rules. Simple modifications without a config equivalent are
+reported on stderr.
+
+Without --profile, imports the selected profile.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return importKarabinerConfig(args[0], importProfile, importOutPath)
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importProfile, "profile", "", "Name of the profile to import (default: the selected one)")
+	importCmd.Flags().StringVar(&importOutPath, "out", "", "Path of the config file to write, which must not exist (default: stdout)")
+}
+
+// importedLayer collects the sub bindings of a hold layer
+type importedLayer struct {
+	key  string
+	subs map[string]KeyBinding
+}
+
+// importer builds the config of an imported profile
+type importer struct {
+	root           *yaml.Node
+	option         map[string]KeyBinding
+	optionModifier string
+	layers         []importedLayer
+	custom         []Rule
+	warnings       []string
+}
+
+func importKarabinerConfig(path, profileName, outPath string) error {
+	karabinerConfig, err := readKarabinerConfig(path)
+	if err != nil {
+		return err
+	}
+
+	var profile *Profile
+	for i, p := range karabinerConfig.Profiles {
+		if (profileName == "" && p.Selected) || (profileName != "" && p.Name == profileName) {
+			profile = &karabinerConfig.Profiles[i]
+			break
+		}
+	}
+	if profile == nil {
+		if profileName != "" {
+			return fmt.Errorf("no profile %q in %s", profileName, path)
+		}
+		return fmt.Errorf("no selected profile in %s, pass one with --profile", path)
+	}
+
+	im := &importer{root: &yaml.Node{Kind: yaml.MappingNode}, option: map[string]KeyBinding{}}
+	im.set("version", 1)
+	im.importSimpleModifications(profile.SimpleModifications)
+	if profile.ComplexModifications != nil {
+		for _, rule := range profile.ComplexModifications.Rules {
+			im.importRule(rule)
+		}
+	}
+	if err := im.finish(); err != nil {
+		return err
+	}
+
+	out, err := encodeYAMLDocument(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{im.root}})
+	if err != nil {
+		return err
+	}
+	for _, warning := range im.warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if outPath == "" {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+	file, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outPath, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(out); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	fmt.Fprintf(os.Stderr, "Config written to: %s\n", outPath)
+	return nil
+}
+
+// set adds a top-level setting to the imported config
+func (im *importer) set(key string, value any) {
+	node := mappingValue(im.root, key, yaml.ScalarNode, true)
+	node.Encode(value)
+}
+
+func (im *importer) importSimpleModifications(modifications []SimpleModification) {
+	for _, modification := range modifications {
+		if modification.From.KeyCode == "grave_accent_and_tilde" && len(modification.To) == 1 && modification.To[0].KeyCode == "non_us_backslash" {
+			im.set("fix_c_c", true)
+			continue
+		}
+		to := make([]string, len(modification.To))
+		for i, key := range modification.To {
+			to[i] = key.KeyCode
+		}
+		im.warnings = append(im.warnings, fmt.Sprintf("simple modification %s -> %s has no config equivalent, add it to a device's simple_modifications", modification.From.KeyCode, strings.Join(to, ",")))
+	}
+}
+
+// sameRule reports whether two rules are identical apart from their description
+func sameRule(a, b Rule) bool {
+	a.Description, b.Description = "", ""
+	aData, _ := json.Marshal(a)
+	bData, _ := json.Marshal(b)
+	return bytes.Equal(aData, bData)
+}
+
+func (im *importer) importRule(rule Rule) {
+	description := strings.TrimPrefix(rule.Description, "[karabingen] ")
+
+	// Presets without settings are recognized by what they generate
+	for _, preset := range []struct {
+		name string
+		rule Rule
+	}{
+		{"disable_left_ctrl", createDisableLeftCtrlRule()},
+		{"disable_command_tab", createDisableCommandTabRule()},
+		{"switch_safari_tabs_hl", createSwitchTabsRule()},
+		{"both_shifts_caps_lock", createBothShiftsCapsLockRule()},
+	} {
+		if sameRule(rule, preset.rule) {
+			im.set(preset.name, true)
+			return
+		}
+	}
+	if len(rule.Manipulators) > 0 && rule.Manipulators[0].From.Modifiers != nil && len(rule.Manipulators[0].From.Modifiers.Mandatory) == 1 {
+		modifiers := rule.Manipulators[0].From.Modifiers
+		if sameRule(rule, createHJKLRule(modifiers.Mandatory[0], modifiers.Optional)) {
+			hjkl := map[string]any{}
+			if modifiers.Mandatory[0] != "option" {
+				hjkl["modifier"] = modifiers.Mandatory[0]
+			}
+			if modifiers.Optional != nil {
+				hjkl["optional"] = modifiers.Optional
+			}
+			if len(hjkl) > 0 {
+				im.set("hjkl", hjkl)
+			}
+			return
+		}
+	}
+
+	if im.importHyperKey(rule) || im.importOptionBinding(rule) || im.importLayer(rule) {
+		return
+	}
+
+	rule.Description = description
+	im.custom = append(im.custom, rule)
+}
+
+// importHyperKey recognizes the rule setting the hyper variable
+func (im *importer) importHyperKey(rule Rule) bool {
+	for _, m := range rule.Manipulators {
+		if len(m.To) == 1 && m.To[0].SetVariable != nil && m.To[0].SetVariable.Name == "hyper" && m.To[0].SetVariable.Value == 1 &&
+			m.From.KeyCode != "" && m.From.Modifiers == nil {
+			im.set("hyperkey", m.From.KeyCode)
+			return true
+		}
+	}
+	return false
+}
+
+// importBinding reverses createBindingTo for the plain binding types
+func importBinding(to To) (KeyBinding, bool) {
+	switch {
+	case to.SoftwareFunction != nil && to.SoftwareFunction.OpenApplication != nil:
+		return KeyBinding{Type: "app", Val: to.SoftwareFunction.OpenApplication.FilePath}, true
+	case to.ShellCommand != "":
+		return KeyBinding{Type: "shell", Val: to.ShellCommand}, true
+	case to.KeyCode != "" && to.SetVariable == nil && to.Repeat == nil:
+		return KeyBinding{Type: "key", Val: strings.Join(append(append([]string{}, to.Modifiers...), to.KeyCode), "+")}, true
+	}
+	return KeyBinding{}, false
+}
+
+// optionalList keeps an empty list of optional modifiers, which differs from
+// none set in the config
+func optionalList(optional []string) ModifierList {
+	if optional == nil {
+		return ModifierList{}
+	}
+	return optional
+}
+
+// bindingFields is the config mapping of an imported binding
+func bindingFields(binding KeyBinding) map[string]any {
+	fields := map[string]any{"type": binding.Type, "val": binding.Val}
+	if binding.Optional != nil {
+		fields["optional"] = []string(binding.Optional)
+	}
+	return fields
+}
+
+// importOptionBinding recognizes a single option+key binding
+func (im *importer) importOptionBinding(rule Rule) bool {
+	if len(rule.Manipulators) != 1 {
+		return false
+	}
+	m := rule.Manipulators[0]
+	if m.Type != "basic" || m.From.KeyCode == "" || m.From.Modifiers == nil || len(m.To) != 1 ||
+		len(m.ToIfAlone)+len(m.ToIfHeldDown)+len(m.ToAfterKeyUp)+len(m.Conditions) > 0 || m.ToDelayedAction != nil || m.Parameters != nil {
+		return false
+	}
+	if len(m.From.Modifiers.Mandatory) != 1 {
+		return false
+	}
+	// All option bindings share one modifier
+	modifier := m.From.Modifiers.Mandatory[0]
+	if modifier != "left_option" && modifier != "right_option" && modifier != "option" ||
+		im.optionModifier != "" && modifier != im.optionModifier {
+		return false
+	}
+	if _, ok := im.option[m.From.KeyCode]; ok {
+		return false
+	}
+	binding, ok := importBinding(m.To[0])
+	if !ok {
+		return false
+	}
+	if !slices.Equal(m.From.Modifiers.Optional, []string{"caps_lock"}) {
+		binding.Optional = optionalList(m.From.Modifiers.Optional)
+	}
+	im.option[m.From.KeyCode] = binding
+	im.optionModifier = modifier
+	return true
+}
+
+// importLayer recognizes a hold layer generated by createLayerRules
+func (im *importer) importLayer(rule Rule) bool {
+	if len(rule.Manipulators) < 2 {
+		return false
+	}
+	toggle := rule.Manipulators[0]
+	if len(toggle.To) != 1 || toggle.To[0].SetVariable == nil || len(toggle.ToAfterKeyUp) != 1 || toggle.ToDelayedAction != nil {
+		return false
+	}
+	key, ok := strings.CutPrefix(toggle.To[0].SetVariable.Name, "hyper_sublayer_")
+	if !ok || key != toggle.From.KeyCode {
+		return false
+	}
+	variable := toggle.To[0].SetVariable.Name
+
+	layer := importedLayer{key: key, subs: map[string]KeyBinding{}}
+	for _, m := range rule.Manipulators[1:] {
+		if len(m.Conditions) != 1 || !reflect.DeepEqual(m.Conditions[0], Condition{Type: "variable_if", Name: variable, Value: 1}) ||
+			m.From.KeyCode == "" || len(m.To) != 1 || len(m.ToIfAlone)+len(m.ToIfHeldDown)+len(m.ToAfterKeyUp) > 0 {
+			return false
+		}
+		binding, ok := importBinding(m.To[0])
+		if !ok {
+			return false
+		}
+		subkey := m.From.KeyCode
+		if m.From.Modifiers != nil {
+			subkey = strings.Join(append(append([]string{}, m.From.Modifiers.Mandatory...), subkey), "+")
+			binding.Optional = m.From.Modifiers.Optional
+		}
+		layer.subs[subkey] = binding
+	}
+	im.layers = append(im.layers, layer)
+	return true
+}
+
+// finish writes the collected bindings and custom rules into the config
+func (im *importer) finish() error {
+	keybindings := &yaml.Node{Kind: yaml.MappingNode}
+	if im.optionModifier != "" && im.optionModifier != "left_option" {
+		mappingValue(keybindings, "option_modifier", yaml.ScalarNode, true).Encode(im.optionModifier)
+	}
+	if len(im.option) > 0 {
+		option := mappingValue(keybindings, "option", yaml.MappingNode, true)
+		keys := make([]string, 0, len(im.option))
+		for key := range im.option {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := mappingValue(option, key, yaml.MappingNode, true).Encode(bindingFields(im.option[key])); err != nil {
+				return err
+			}
+		}
+	}
+	if len(im.layers) > 0 {
+		layers := mappingValue(keybindings, "layers", yaml.SequenceNode, true)
+		for _, layer := range im.layers {
+			// The most common type becomes the layer type
+			counts := map[string]int{}
+			layerType := ""
+			for _, binding := range layer.subs {
+				counts[binding.Type]++
+				if counts[binding.Type] > counts[layerType] || (counts[binding.Type] == counts[layerType] && binding.Type < layerType) {
+					layerType = binding.Type
+				}
+			}
+
+			node := &yaml.Node{Kind: yaml.MappingNode}
+			mappingValue(node, "key", yaml.ScalarNode, true).Encode(layer.key)
+			mappingValue(node, "type", yaml.ScalarNode, true).Encode(layerType)
+			sub := mappingValue(node, "sub", yaml.MappingNode, true)
+			subkeys := make([]string, 0, len(layer.subs))
+			for subkey := range layer.subs {
+				subkeys = append(subkeys, subkey)
+			}
+			sort.Strings(subkeys)
+			for _, subkey := range subkeys {
+				binding := layer.subs[subkey]
+				var value any = binding.Val
+				if binding.Type != layerType || binding.Optional != nil {
+					value = bindingFields(binding)
+				}
+				if err := mappingValue(sub, subkey, yaml.ScalarNode, true).Encode(value); err != nil {
+					return err
+				}
+			}
+			layers.Content = append(layers.Content, node)
+		}
+	}
+	if len(keybindings.Content) > 0 {
+		im.root.Content = append(im.root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "keybindings"}, keybindings)
+	}
+
+	if len(im.custom) > 0 {
+		// JSON is valid YAML, so parsing it keeps Karabiner's field names and order
+		data, err := json.Marshal(im.custom)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		clearNodeStyle(&doc)
+		im.root.Content = append(im.root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "custom_rules"}, doc.Content[0])
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// keyDefinitionsURL lists every key code Karabiner-Elements offers in its UI
+const keyDefinitionsURL = "https://raw.githubusercontent.com/pqrs-org/Karabiner-Elements/main/src/apps/SettingsWindow/Resources/simple_modifications.json"
+
+// embeddedKeyDefinitions is a snapshot of the key code list, used until
+// "karabingen keys update" caches a newer one
+//
+//go:embed keys.json
+var embeddedKeyDefinitions []byte
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Karabiner key code definitions",
+	Long:  `Commands for managing the cached Karabiner-Elements key code definitions.`,
+}
+
+var updateKeysCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Fetch the latest key code definitions",
+	Long: `Fetch the key code definitions from the Karabiner-Elements repository into
+~/.cache/karabingen/keys.json. The cache replaces the key code list built into
+karabingen, e.g. for key codes added by a newer Karabiner-Elements.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateKeyDefinitions()
+	},
+}
+
+var listKeysCmd = &cobra.Command{
+	Use:          "list [key_code|consumer_key_code|pointing_button]",
+	Short:        "List known key codes",
+	Long:         `Print the known key codes one per line, e.g. for editor completion.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		definitions, err := readKeyDefinitions()
+		if err != nil {
+			return err
+		}
+
+		kinds := []string{"key_code", "consumer_key_code", "pointing_button"}
+		if len(args) > 0 {
+			kinds = args[:1]
+		}
+		if jsonOutput {
+			listed := keyDefinitions{}
+			for _, kind := range kinds {
+				listed[kind] = definitions[kind]
+			}
+			return printJSON(listed)
+		}
+		for _, kind := range kinds {
+			for _, name := range definitions[kind] {
+				fmt.Println(name)
+			}
+		}
+		return nil
+	},
+}
+
+// keyDefinitions maps a kind (key_code, consumer_key_code, pointing_button) to its names
+type keyDefinitions map[string][]string
+
+func keyDefinitionsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "karabingen", "keys.json"), nil
+}
+
+func updateKeyDefinitions() error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(keyDefinitionsURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch key definitions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch key definitions: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read key definitions: %w", err)
+	}
+
+	var categories []struct {
+		Data []map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(data, &categories); err != nil {
+		return fmt.Errorf("failed to parse key definitions: %w", err)
+	}
+
+	definitions := keyDefinitions{}
+	seen := map[string]bool{}
+	for _, category := range categories {
+		for _, entry := range category.Data {
+			for _, kind := range []string{"key_code", "consumer_key_code", "pointing_button"} {
+				name, ok := entry[kind].(string)
+				if !ok || seen[kind+"/"+name] {
+					continue
+				}
+				seen[kind+"/"+name] = true
+				definitions[kind] = append(definitions[kind], name)
+			}
+		}
+	}
+	if len(definitions["key_code"]) == 0 {
+		return fmt.Errorf("no key codes found in %s", keyDefinitionsURL)
+	}
+	for _, names := range definitions {
+		sort.Strings(names)
+	}
+
+	path, err := keyDefinitionsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	out, err := json.MarshalIndent(definitions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		return fmt.Errorf("failed to write key definitions: %w", err)
+	}
+
+	fmt.Printf("Cached %d key codes, %d consumer key codes and %d pointing buttons in %s\n",
+		len(definitions["key_code"]), len(definitions["consumer_key_code"]), len(definitions["pointing_button"]), path)
+	return nil
+}
+
+// readKeyDefinitions returns the cached definitions, or the built-in snapshot
+// when nothing is cached
+func readKeyDefinitions() (keyDefinitions, error) {
+	path, err := keyDefinitionsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data, path = embeddedKeyDefinitions, "built-in key definitions"
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read key definitions: %w", err)
+	}
+
+	var definitions keyDefinitions
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return definitions, nil
+}
+
+// contains reports whether name is a known definition of the given kind. The
+// lists are sorted, and "either_<modifier>" stands for both sides of it.
+func (d keyDefinitions) contains(kind, name string) bool {
+	if base, ok := strings.CutPrefix(name, eitherPrefix); ok {
+		name = "left_" + base
+	}
+	names := d[kind]
+	i := sort.SearchStrings(names, name)
+	return i < len(names) && names[i] == name
+}
+
+// cachedKeyDefinitions reads the key definitions once per run, keeping the
+// error along with them
+var cachedKeyDefinitions = sync.OnceValues(readKeyDefinitions)
+
+// validateKeyCode checks name against the known definitions of the given kind
+func validateKeyCode(kind, name string) error {
+	definitions, err := cachedKeyDefinitions()
+	if err != nil {
+		return err
+	}
+
+	if definitions.contains(kind, name) {
+		return nil
+	}
+	return fmt.Errorf("unknown %s %q (run \"karabingen keys list %s\" to see valid names)", kind, name, kind)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+const leaderVariable = "leader"
+
+// leaderNode is a step in the leader sequence tree. Every node that expects
+// more keys gets its own state value of the leader variable.
+type leaderNode struct {
+	state    int
+	keys     []string
+	parent   *leaderNode
+	children map[string]*leaderNode
+	binding  *KeyBinding
+}
+
+func createLeaderRule(config *Config, leader LeaderConfig) (Rule, error) {
+	if len(leader.Sequences) == 0 {
+		return Rule{}, fmt.Errorf("leader key %s has no sequences", leader.Key)
+	}
+
+	// Build sequence tree, state 1 means "leader pressed"
+	nextState := 1
+	root := &leaderNode{state: nextState, children: map[string]*leaderNode{}}
+	var order []*leaderNode
+
+	for i := range leader.Sequences {
+		sequence := leader.Sequences[i]
+		if len(sequence.Keys) == 0 {
+			return Rule{}, fmt.Errorf("leader sequence %d has no keys", i+1)
+		}
+
+		node := root
+		for j, key := range sequence.Keys {
+			if node.binding != nil {
+				return Rule{}, fmt.Errorf("leader sequence %s is shadowed by %s",
+					strings.Join(sequence.Keys, " "), strings.Join(node.keys, " "))
+			}
+
+			child, ok := node.children[key]
+			if !ok {
+				child = &leaderNode{keys: sequence.Keys[:j+1], parent: node, children: map[string]*leaderNode{}}
+				node.children[key] = child
+				order = append(order, child)
+			}
+			node = child
+		}
+
+		if node.binding != nil || len(node.children) > 0 {
+			return Rule{}, fmt.Errorf("leader sequence %s is defined more than once or is a prefix of another sequence",
+				strings.Join(sequence.Keys, " "))
+		}
+		node.binding = &sequence.KeyBinding
+	}
+
+	reset := []To{{SetVariable: &SetVariable{Name: leaderVariable, Value: 0}}}
+	timeout := &ToDelayedAction{ToIfInvoked: reset}
+	parameters := &Parameters{BasicToDelayedActionDelayMilliseconds: leader.TimeoutMs}
+
+	var leaderModifiers *Modifiers
+	if len(leader.Modifiers) > 0 {
+		leaderModifiers = &Modifiers{Mandatory: leader.Modifiers}
+	}
+
+	// Leader key activates the sequence mode
+	manipulators := []Manipulator{
+		{
+			Type:        "basic",
+			Description: fmt.Sprintf("Leader %s", leader.Key),
+			From: From{
+				KeyCode:   leader.Key,
+				Modifiers: leaderModifiers,
+			},
+			To: []To{
+				{SetVariable: &SetVariable{Name: leaderVariable, Value: root.state}},
+			},
+			ToDelayedAction: timeout,
+			Conditions: []Condition{
+				{Type: "variable_if", Name: leaderVariable, Value: 0},
+			},
+			Parameters: parameters,
+		},
+	}
+
+	// Assign states to intermediate nodes in definition order
+	for _, node := range order {
+		if node.binding == nil {
+			nextState++
+			node.state = nextState
+		}
+	}
+
+	// Walk the tree in definition order so the output is stable
+	for _, node := range order {
+		key := node.keys[len(node.keys)-1]
+		condition := []Condition{
+			{Type: "variable_if", Name: leaderVariable, Value: node.parent.state},
+		}
+
+		if node.binding != nil {
+			actions, err := createBindingTos(config, *node.binding)
+			if err != nil {
+				return Rule{}, fmt.Errorf("leader sequence %s: %w", strings.Join(node.keys, " "), err)
+			}
+
+			manipulators = append(manipulators, Manipulator{
+				Type:        "basic",
+				Description: fmt.Sprintf("Leader %s → %s", strings.Join(node.keys, " "), bindingLabel(*node.binding)),
+				From: From{
+					KeyCode: key,
+				},
+				To:         append(actions, reset...),
+				Conditions: condition,
+			})
+			continue
+		}
+
+		manipulators = append(manipulators, Manipulator{
+			Type:        "basic",
+			Description: fmt.Sprintf("Leader %s …", strings.Join(node.keys, " ")),
+			From: From{
+				KeyCode: key,
+			},
+			To: []To{
+				{SetVariable: &SetVariable{Name: leaderVariable, Value: node.state}},
+			},
+			ToDelayedAction: timeout,
+			Conditions:      condition,
+			Parameters:      parameters,
+		})
+	}
+
+	// Any other key cancels the sequence
+	manipulators = append(manipulators, Manipulator{
+		Type:        "basic",
+		Description: "Leader: cancel on unknown key",
+		From: From{
+			Any: "key_code",
+		},
+		To: reset,
+		Conditions: []Condition{
+			{Type: "variable_unless", Name: leaderVariable, Value: 0},
+		},
+	})
+
+	return Rule{
+		Description:  fmt.Sprintf("Leader key (%s)", leader.Key),
+		Manipulators: manipulators,
+	}, nil
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [config_path|config_url]",
+	Short: "Find bindings that can never fire",
+	Long: `Check the generated rules for bindings that can never fire, because an earlier
+manipulator always matches the same key first: e.g. a layer sub-key that is
+also the hyper key, a hold layer's sub-key equal to the layer key, or an option
+binding on a key of the tmux jump set. Also warns about shell commands that
+seem to embed a token, which should be read from the Keychain or the
+environment instead, and about key events sent after an app or shell action,
+which Karabiner doesn't wait for.
+Without an argument, uses $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := ""
+		if len(args) > 0 {
+			configPath = args[0]
+		}
+		config, err := loadDefaultConfig(configPath)
+		if err != nil {
+			return err
+		}
+		problems, err := lintConfig(config)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			if problems == nil {
+				problems = []string{}
+			}
+			if err := printJSON(struct {
+				Problems []string `json:"problems"`
+			}{problems}); err != nil {
+				return err
+			}
+		} else {
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problems found", len(problems))
+		}
+		return nil
+	},
+}
+
+// lintConfig returns a description of every binding that can never fire, in
+// every profile of the config
+func lintConfig(config *Config) ([]string, error) {
+	return checkProfiles(config, lintProfile)
+}
+
+// checkProfiles runs check on every profile of the config, prefixing the
+// problems found with the profile name
+func checkProfiles(config *Config, check func(config *Config) ([]string, error)) ([]string, error) {
+	if len(config.Profiles) == 0 {
+		return check(config)
+	}
+	var problems []string
+	for _, profile := range config.Profiles {
+		found, err := check(profile.config)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		for _, problem := range found {
+			problems = append(problems, fmt.Sprintf("profile %s: %s", profile.Name, problem))
+		}
+	}
+	return problems, nil
+}
+
+func lintProfile(config *Config) ([]string, error) {
+	var problems []string
+
+	// A hold layer is only active while its key is held, and pressing the key
+	// again hits the layer toggle
+	for _, layer := range config.Keybindings.Layers {
+		if layer.Mode != "" && layer.Mode != "hold" {
+			continue
+		}
+		for subkey := range layer.Sub {
+			if subkey == layer.Key {
+				problems = append(problems, fmt.Sprintf("layer %s: sub-key %s is the layer key, which toggles the layer instead", layer.Key, subkey))
+			}
+		}
+	}
+
+	presetRules, err := createRules(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, generated := range presetRules {
+		for _, m := range generated.rule.Manipulators {
+			tokenFound := false
+			for _, command := range shellCommands(m) {
+				if err := checkShellCommand(command); err != nil {
+					problems = append(problems, fmt.Sprintf("%q: shell command will fail: %v", generated.rule.Description, err))
+				}
+				// Tokens written into shell commands end up in karabiner.json,
+				// reported once per manipulator
+				if token := embeddedToken(command); token != "" && !tokenFound {
+					problems = append(problems, fmt.Sprintf("%q: shell command seems to embed a token (%s...), read it with {keychain:<item>} or {env:<name>} instead", generated.rule.Description, token))
+					tokenFound = true
+				}
+			}
+			if keysAfterAsync(m.To) {
+				problems = append(problems, fmt.Sprintf("%q: key events after an app or shell action may arrive before it runs, combine them into one shell command", generated.rule.Description))
+			}
+		}
+	}
+
+	type located struct {
+		rule        string
+		manipulator Manipulator
+	}
+	var earlier []located
+	for _, generated := range presetRules {
+		for _, m := range generated.rule.Manipulators {
+			if m.From.KeyCode == "" {
+				continue
+			}
+			for _, e := range earlier {
+				if shadows(e.manipulator, m) {
+					problems = append(problems, fmt.Sprintf("%q: %s never fires, %q matches it first", generated.rule.Description, fromLabel(m.From), e.rule))
+					break
+				}
+			}
+			earlier = append(earlier, located{generated.rule.Description, m})
+		}
+	}
+	return problems, nil
+}
+
+// tokenPatterns match credentials written out: well-known token prefixes,
+// bearer tokens and long values of token-like parameters
+var tokenPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:ghp_|gho_|github_pat_|glpat-|xox[abpr]-|sk-|AKIA)[A-Za-z0-9_-]{12,}`),
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}`),
+	regexp.MustCompile(`(?i)(?:token|api[_-]?key|secret|password|passwd)['"]?\s*[=:]\s*['"]?[A-Za-z0-9._~+/-]{12,}`),
+}
+
+// embeddedToken returns the start of a token found in a command, or ""
+func embeddedToken(command string) string {
+	for _, pattern := range tokenPatterns {
+		if match := pattern.FindString(command); match != "" {
+			return match[:min(len(match), 8)]
+		}
+	}
+	return ""
+}
+
+// shellCommands returns the shell commands a manipulator runs
+func shellCommands(m Manipulator) []string {
+	events := slices.Concat(m.To, m.ToIfAlone, m.ToIfHeldDown, m.ToAfterKeyUp)
+	if m.ToDelayedAction != nil {
+		events = slices.Concat(events, m.ToDelayedAction.ToIfInvoked, m.ToDelayedAction.ToIfCanceled)
+	}
+	var commands []string
+	for _, event := range events {
+		if event.ShellCommand != "" {
+			commands = append(commands, event.ShellCommand)
+		}
+	}
+	return commands
+}
+
+// keysAfterAsync reports whether key events follow a shell command or an
+// application launch, which Karabiner starts without waiting for them
+func keysAfterAsync(events []To) bool {
+	async := false
+	for _, event := range events {
+		switch {
+		case event.ShellCommand != "" || event.SoftwareFunction != nil:
+			async = true
+		case async && (event.KeyCode != "" || event.ConsumerKeyCode != ""):
+			return true
+		}
+	}
+	return false
+}
+
+// fromLabel describes a from event, e.g. "option+control+a"
+func fromLabel(from From) string {
+	if from.Modifiers == nil || len(from.Modifiers.Mandatory) == 0 {
+		return from.KeyCode
+	}
+	return strings.Join(from.Modifiers.Mandatory, "+") + "+" + from.KeyCode
+}
+
+// modifierMatches reports whether modifier a accepts b, where a generic
+// modifier like "option" accepts both sides
+func modifierMatches(a, b string) bool {
+	return a == b || strings.TrimPrefix(strings.TrimPrefix(b, "left_"), "right_") == a
+}
+
+// shadows reports whether manipulator a matches every event b matches, so b
+// never fires when a comes first
+func shadows(a, b Manipulator) bool {
+	if a.From.KeyCode != b.From.KeyCode {
+		return false
+	}
+
+	// a's conditions must hold whenever b's do
+	for _, condition := range a.Conditions {
+		found := false
+		for _, other := range b.Conditions {
+			if condition.Type == other.Type && condition.Name == other.Name && condition.Value == other.Value &&
+				slices.Equal(condition.BundleIdentifiers, other.BundleIdentifiers) && reflect.DeepEqual(condition.Identifiers, other.Identifiers) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	var aMandatory, aOptional, bMandatory []string
+	if a.From.Modifiers != nil {
+		aMandatory, aOptional = a.From.Modifiers.Mandatory, a.From.Modifiers.Optional
+	}
+	if b.From.Modifiers != nil {
+		bMandatory = b.From.Modifiers.Mandatory
+	}
+
+	// Every modifier a requires is required by b, and the rest of b's are
+	// allowed by a
+	remaining := slices.Clone(bMandatory)
+	for _, modifier := range aMandatory {
+		i := slices.IndexFunc(remaining, func(other string) bool { return modifierMatches(modifier, other) })
+		if i < 0 {
+			return false
+		}
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+	for _, modifier := range remaining {
+		if !slices.ContainsFunc(aOptional, func(optional string) bool {
+			return optional == "any" || modifierMatches(optional, modifier)
+		}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// jumplistGroupPrefix starts a group header line in the jumplist; other lines
+// starting with "#" are plain comments
+const jumplistGroupPrefix = "## "
+
+var listTmuxCmd = &cobra.Command{
+	Use:   "list [jumplist_file]",
+	Short: "List the tmux jump list",
+	Long: `List the bookmarks of the tmux jump list along with their groups.
+If no jumplist file is specified, defaults to ~/.tmuxjumplist.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jumplistFile := "~/.tmuxjumplist"
+		if len(args) >= 1 {
+			jumplistFile = args[0]
+		}
+		if strings.HasPrefix(jumplistFile, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+			jumplistFile = filepath.Join(home, jumplistFile[2:])
+		}
+		return listJumplist(jumplistFile)
+	},
+}
+
+// jumplistGroup returns the group name of a group header line
+func jumplistGroup(line string) (string, bool) {
+	name, ok := strings.CutPrefix(strings.TrimSpace(line), jumplistGroupPrefix)
+	return strings.TrimSpace(name), ok
+}
+
+// jumplistEntry is a bookmark of the jumplist, as printed by tmux list --json
+type jumplistEntry struct {
+	Key       string `json:"key"`
+	Session   string `json:"session"`
+	Directory string `json:"directory,omitempty"`
+	Group     string `json:"group,omitempty"`
+}
+
+// readJumplistEntries returns the bookmarks of a jumplist file
+func readJumplistEntries(jumplistFile string) ([]jumplistEntry, error) {
+	lines, err := readJumplistLines(jumplistFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jumplist %s: %w", jumplistFile, err)
+	}
+
+	entries := []jumplistEntry{}
+	group := ""
+	for _, line := range lines {
+		if name, ok := jumplistGroup(line); ok {
+			group = name
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Format: key:session_name, key:session_name:directory or key:session_name:directory:socket
+		parts := strings.Split(line, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		directory := ""
+		if len(parts) >= 3 {
+			directory = strings.TrimSpace(parts[2])
+		}
+		entries = append(entries, jumplistEntry{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), directory, group})
+	}
+	return entries, nil
+}
+
+func listJumplist(jumplistFile string) error {
+	entries, err := readJumplistEntries(jumplistFile)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(entries)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Key, entry.Session, entry.Directory, entry.Group)
+	}
+	return w.Flush()
+}
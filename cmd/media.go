@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+)
+
+// mediaPlayers maps the supported players to their AppleScript application names
+var mediaPlayers = map[string]string{
+	"music":   "Music",
+	"spotify": "Spotify",
+}
+
+// createMediaLayer builds a layer controlling Apple Music or Spotify through AppleScript
+func createMediaLayer(mediaConfig MediaLayerConfig) (LayerConfig, error) {
+	app, ok := mediaPlayers[mediaConfig.Player]
+	if !ok {
+		return LayerConfig{}, fmt.Errorf("unsupported media player: %s (supported: music, spotify)", mediaConfig.Player)
+	}
+
+	tell := func(command string) LayerBinding {
+		return LayerBinding{Val: osascriptCommand(fmt.Sprintf(`tell application "%s" to %s`, app, command))}
+	}
+
+	// Music can favorite the current track directly, Spotify only through its
+	// "Save to Liked Songs" shortcut
+	like := tell("set favorited of current track to true")
+	if mediaConfig.Player == "spotify" {
+		like = LayerBinding{Val: osascriptCommand(
+			`tell application "Spotify" to activate`,
+			`tell application "System Events" to keystroke "b" using {option down, shift down}`,
+		)}
+	}
+
+	return LayerConfig{
+		Key:  mediaConfig.Key,
+		Type: "shell",
+		Sub: map[string]LayerBinding{
+			"p": tell("playpause"),
+			"n": tell("next track"),
+			"b": tell("previous track"),
+			"l": like,
+			"k": tell("set sound volume to sound volume + 10"),
+			"j": tell("set sound volume to sound volume - 10"),
+		},
+	}, nil
+}
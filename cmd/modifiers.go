@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// eitherPrefix marks a modifier that may be pressed on either side, e.g. "either_command"
+const eitherPrefix = "either_"
+
+// expandEitherModifiers resolves "either_<modifier>" in a rule. Karabiner matches
+// both sides for the generic name in modifier lists, but a modifier used as the
+// from key needs a manipulator per side.
+func expandEitherModifiers(rule Rule) Rule {
+	manipulators := make([]Manipulator, 0, len(rule.Manipulators))
+	for _, m := range rule.Manipulators {
+		m.From.Modifiers = genericModifiers(m.From.Modifiers)
+
+		base, ok := strings.CutPrefix(m.From.KeyCode, eitherPrefix)
+		if !ok {
+			manipulators = append(manipulators, replaceKeyCode(m, "", ""))
+			continue
+		}
+		for _, side := range []string{"left_", "right_"} {
+			manipulators = append(manipulators, replaceKeyCode(m, m.From.KeyCode, side+base))
+		}
+	}
+	rule.Manipulators = manipulators
+	return rule
+}
+
+// genericModifiers maps "either_<modifier>" to the side-agnostic "<modifier>"
+func genericModifiers(modifiers *Modifiers) *Modifiers {
+	if modifiers == nil {
+		return nil
+	}
+	return &Modifiers{
+		Mandatory: genericModifierList(modifiers.Mandatory),
+		Optional:  genericModifierList(modifiers.Optional),
+	}
+}
+
+func genericModifierList(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	generic := make([]string, len(list))
+	for i, name := range list {
+		generic[i] = strings.TrimPrefix(name, eitherPrefix)
+	}
+	return generic
+}
+
+// replaceKeyCode copies a manipulator with the from key (and any event sending
+// it back, e.g. to_if_alone) replaced, and either_ modifiers made generic
+func replaceKeyCode(m Manipulator, oldKey, newKey string) Manipulator {
+	replace := func(events []To) []To {
+		if events == nil {
+			return nil
+		}
+		replaced := make([]To, len(events))
+		for i, to := range events {
+			if oldKey != "" && to.KeyCode == oldKey {
+				to.KeyCode = newKey
+			}
+			to.Modifiers = genericModifierList(to.Modifiers)
+			replaced[i] = to
+		}
+		return replaced
+	}
+
+	if oldKey != "" {
+		m.From.KeyCode = newKey
+	}
+	m.To = replace(m.To)
+	m.ToIfAlone = replace(m.ToIfAlone)
+	m.ToIfHeldDown = replace(m.ToIfHeldDown)
+	m.ToAfterKeyUp = replace(m.ToAfterKeyUp)
+	if m.ToDelayedAction != nil {
+		m.ToDelayedAction = &ToDelayedAction{
+			ToIfInvoked:  replace(m.ToDelayedAction.ToIfInvoked),
+			ToIfCanceled: replace(m.ToDelayedAction.ToIfCanceled),
+		}
+	}
+	return m
+}
+
+// chordModifiers maps the modifier names accepted in key chords such as
+// "cmd+shift+4" to Karabiner modifier names
+var chordModifiers = map[string]string{
+	"cmd": "command", "command": "command", "left_command": "left_command", "right_command": "right_command",
+	"ctrl": "control", "control": "control", "left_control": "left_control", "right_control": "right_control",
+	"opt": "option", "alt": "option", "option": "option", "left_option": "left_option", "right_option": "right_option",
+	"shift": "shift", "left_shift": "left_shift", "right_shift": "right_shift",
+	"fn": "fn", "caps_lock": "caps_lock",
+}
+
+// chordKeys maps common key names to Karabiner key codes
+var chordKeys = map[string]string{
+	"esc":       "escape",
+	"enter":     "return_or_enter",
+	"return":    "return_or_enter",
+	"space":     "spacebar",
+	"backspace": "delete_or_backspace",
+	"del":       "delete_forward",
+	"up":        "up_arrow",
+	"down":      "down_arrow",
+	"left":      "left_arrow",
+	"right":     "right_arrow",
+	"pgup":      "page_up",
+	"pgdown":    "page_down",
+}
+
+// parseKeyChord splits a key chord such as "cmd+shift+4" into its key code and modifiers
+func parseKeyChord(chord string) (string, []string, error) {
+	parts := strings.Split(strings.ToLower(chord), "+")
+	key := strings.TrimSpace(parts[len(parts)-1])
+	if key == "" {
+		return "", nil, fmt.Errorf("key chord %q has no key", chord)
+	}
+	if code, ok := chordKeys[key]; ok {
+		key = code
+	}
+
+	modifiers := make([]string, 0, len(parts)-1)
+	for _, part := range parts[:len(parts)-1] {
+		name := strings.TrimSpace(part)
+		modifier, ok := chordModifiers[strings.TrimPrefix(name, eitherPrefix)]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown modifier %q in key chord %q", name, chord)
+		}
+		modifiers = append(modifiers, modifier)
+	}
+	return key, modifiers, nil
+}
+
+// modifierPresets are the simple modifications of the modifier_presets options
+var modifierPresets = map[string]map[string]string{
+	// PC keyboards have option where the Mac keyboard has command
+	"pc": {
+		"left_command": "left_option", "left_option": "left_command",
+		"right_command": "right_option", "right_option": "right_command",
+	},
+	"fn_ctrl": {"fn": "left_control", "left_control": "fn"},
+	// Control next to a, as on Sun and HHKB keyboards
+	"unix": {"caps_lock": "left_control", "left_control": "caps_lock"},
+}
+
+// resolveModifierPresets merges the named presets into one from -> to mapping
+func resolveModifierPresets(names []string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, name := range names {
+		preset, ok := modifierPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown modifier preset %q (supported: fn_ctrl, pc, unix)", name)
+		}
+		for from, to := range preset {
+			if _, ok := keys[from]; ok {
+				return nil, fmt.Errorf("modifier preset %s remaps %s, which another preset already remaps", name, from)
+			}
+			keys[from] = to
+		}
+	}
+	return keys, nil
+}
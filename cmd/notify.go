@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// postNotification shows a macOS user notification, so background runs
+// (launchd, file watchers) give visible feedback
+func postNotification(message string) {
+	script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString("karabingen"))
+	if err := exec.Command("osascript", "-e", script).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to post notification: %v\n", err)
+	}
+}
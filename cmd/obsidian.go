@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// createObsidianURI builds an obsidian:// URI from a binding value:
+// "daily", "capture", "open:<file>" or "search:<query>"
+func createObsidianURI(obsidianConfig ObsidianConfig, val string) (string, error) {
+	action, arg, _ := strings.Cut(val, ":")
+
+	params := url.Values{}
+	if obsidianConfig.Vault != "" {
+		params.Set("vault", obsidianConfig.Vault)
+	}
+
+	switch action {
+	case "daily":
+	case "capture":
+		// Append clipboard contents to the inbox note
+		action = "new"
+		params.Set("file", obsidianConfig.Inbox)
+		params.Set("append", "true")
+		params.Set("clipboard", "true")
+	case "open":
+		params.Set("file", arg)
+	case "search":
+		params.Set("query", arg)
+	default:
+		return "", fmt.Errorf("unknown obsidian action %q (supported: daily, capture, open:<file>, search:<query>)", val)
+	}
+
+	uri := "obsidian://" + action
+	if len(params) > 0 {
+		uri += "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+	}
+	return uri, nil
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Karabiner runs shell commands from karabiner_console_user_server, so macOS
+// asks about that process, not osascript or karabingen, before letting them
+// send Apple events or script the UI
+const karabinerShellServer = "karabiner_console_user_server"
+
+var (
+	tellApplicationPattern = regexp.MustCompile(`tell application "([^"]+)"`)
+	uiScriptingPattern     = regexp.MustCompile(`\b(keystroke|key code|click|windows of process|tell process)\b`)
+	terminalFlagPattern    = regexp.MustCompile(`--terminal '?([\w.-]+)`)
+)
+
+// karabingenAppleScript lists what the karabingen commands run from rules
+// drive through AppleScript at runtime
+var karabingenAppleScript = map[string]struct {
+	apps          []string
+	accessibility bool
+	terminal      bool
+}{
+	"tmux switch": {apps: []string{"System Events"}, accessibility: true, terminal: true},
+	"switcher":    {apps: []string{"System Events"}, accessibility: true, terminal: true},
+	"browser":     {apps: []string{"Safari"}},
+	"safari":      {apps: []string{"Safari"}},
+	"popup":       {apps: []string{"Finder"}, terminal: true},
+}
+
+// appleScriptNeeds is what the shell commands of generated rules need to run:
+// Automation permission for each app they send Apple events to, and
+// Accessibility permission for UI scripting through System Events
+type appleScriptNeeds struct {
+	apps          []string
+	accessibility bool
+}
+
+// collectAppleScriptNeeds scans the shell commands of rules for AppleScript run
+// directly with osascript or by a karabingen command
+func collectAppleScriptNeeds(rules []Rule, terminals map[string]TerminalConfig) appleScriptNeeds {
+	var needs appleScriptNeeds
+	addApp := func(app string) {
+		if app != "" && !slices.Contains(needs.apps, app) {
+			needs.apps = append(needs.apps, app)
+		}
+	}
+	for _, rule := range rules {
+		for _, m := range rule.Manipulators {
+			for _, command := range shellCommands(m) {
+				if strings.Contains(command, "osascript") {
+					for _, match := range tellApplicationPattern.FindAllStringSubmatch(command, -1) {
+						addApp(match[1])
+					}
+					if strings.Contains(command, "System Events") && uiScriptingPattern.MatchString(command) {
+						needs.accessibility = true
+					}
+				}
+				if !karabingenPathPattern.MatchString(command) {
+					continue
+				}
+				for subcommand, uses := range karabingenAppleScript {
+					if !strings.Contains(command, "karabingen "+subcommand) && !strings.Contains(command, "karabingen' "+subcommand) {
+						continue
+					}
+					for _, app := range uses.apps {
+						addApp(app)
+					}
+					needs.accessibility = needs.accessibility || uses.accessibility
+					if uses.terminal {
+						name := "alacritty"
+						if match := terminalFlagPattern.FindStringSubmatch(command); match != nil {
+							name = match[1]
+						}
+						if terminal, err := lookupTerminal(terminals, name); err == nil {
+							addApp(terminal.App)
+						}
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(needs.apps)
+	return needs
+}
+
+// checkAppleScriptPermissions returns what keeps the AppleScript of the rules
+// from running, with how to fix it. Rules without AppleScript need nothing.
+func checkAppleScriptPermissions(rules []Rule, terminals map[string]TerminalConfig) []string {
+	needs := collectAppleScriptNeeds(rules, terminals)
+	if len(needs.apps) == 0 && !needs.accessibility {
+		return nil
+	}
+	if _, err := exec.LookPath("osascript"); err != nil {
+		return []string{"osascript not found, bindings running AppleScript fail silently; AppleScript needs macOS"}
+	}
+
+	var problems []string
+	settings := "System Settings > Privacy & Security"
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return []string{fmt.Sprintf("failed to get home directory: %v", err)}
+	}
+	automation, err := readTCCGrants(filepath.Join(home, "Library/Application Support/com.apple.TCC/TCC.db"), "kTCCServiceAppleEvents")
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("can't read the Automation permissions (%v), give the terminal Full Disk Access to check them, "+
+			"or check in %s > Automation that %s may control %s", err, settings, karabinerShellServer, strings.Join(needs.apps, ", ")))
+	} else {
+		for _, app := range needs.apps {
+			bundleID, err := applicationID(app)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s is not installed, bindings scripting it fail", app))
+				continue
+			}
+			switch granted, asked := automation[bundleID]; {
+			case !asked:
+				problems = append(problems, fmt.Sprintf("%s has not been allowed to control %s yet, press a binding using it and allow the prompt", karabinerShellServer, app))
+			case !granted:
+				problems = append(problems, fmt.Sprintf("%s is denied control of %s, allow it in %s > Automation", karabinerShellServer, app, settings))
+			}
+		}
+	}
+
+	if needs.accessibility {
+		accessibility, err := readTCCGrants("/Library/Application Support/com.apple.TCC/TCC.db", "kTCCServiceAccessibility")
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("can't read the Accessibility permissions (%v), give the terminal Full Disk Access to check them, "+
+				"or check in %s > Accessibility that %s is listed and enabled", err, settings, karabinerShellServer))
+		case !accessibility["UNUSED"]:
+			problems = append(problems, fmt.Sprintf("%s has no Accessibility permission, keystrokes and window switching through System Events fail; "+
+				"add it in %s > Accessibility (it is in /Library/Application Support/org.pqrs/Karabiner-Elements/bin)", karabinerShellServer, settings))
+		}
+	}
+	return problems
+}
+
+// readTCCGrants reads the decisions of a TCC database for the Karabiner shell
+// server and a service, by target bundle identifier ("UNUSED" for services
+// without one). Reading it needs Full Disk Access.
+func readTCCGrants(path, service string) (map[string]bool, error) {
+	query := fmt.Sprintf("SELECT indirect_object_identifier, auth_value FROM access WHERE service = '%s' AND client LIKE '%%%s%%'", service, karabinerShellServer)
+	output, err := exec.Command("sqlite3", "-readonly", "-separator", "|", path, query).CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return nil, fmt.Errorf("%s", message)
+		}
+		return nil, err
+	}
+	grants := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		target, value, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		// auth_value 2 is allowed, 0 denied
+		grants[target] = grants[target] || value == "2"
+	}
+	return grants, nil
+}
+
+// applicationID returns the bundle identifier of an installed application
+func applicationID(app string) (string, error) {
+	output, err := exec.Command("osascript", "-e", "id of application "+appleScriptString(app)).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	popupConfigPath string
+	popupTerminal   string
+	popupColumns    int
+	popupLines      int
+)
+
+var popupCmd = &cobra.Command{
+	Use:   "popup [flags] -- <command>",
+	Short: "Run a command in a small centered terminal window",
+	Long: `Open a small terminal window in the middle of the screen running the given
+command; the window closes when the command exits. A building block for
+fzf-based pickers triggered from Karabiner bindings, e.g.
+
+  karabingen popup -- karabingen browser switch
+
+Without flags, the terminal and size come from the popup section of the config.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		popup, terminals, err := loadPopupConfig(popupConfigPath)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("terminal") {
+			popup.Terminal = popupTerminal
+		}
+		if cmd.Flags().Changed("columns") {
+			popup.Columns = popupColumns
+		}
+		if cmd.Flags().Changed("lines") {
+			popup.Lines = popupLines
+		}
+		terminal, err := lookupTerminal(terminals, popup.Terminal)
+		if err != nil {
+			return err
+		}
+		return openPopup(popup, terminal, strings.Join(args, " "))
+	},
+}
+
+func init() {
+	popupCmd.Flags().StringVar(&popupConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	popupCmd.Flags().StringVar(&popupTerminal, "terminal", "", "Terminal to use (alacritty, ghostty, iterm2, terminal or one of the terminals section)")
+	popupCmd.Flags().IntVar(&popupColumns, "columns", 0, "Window width in columns")
+	popupCmd.Flags().IntVar(&popupLines, "lines", 0, "Window height in lines")
+}
+
+// loadPopupConfig reads the popup settings and the configured terminals,
+// falling back to defaults without a config
+func loadPopupConfig(configPath string) (PopupConfig, map[string]TerminalConfig, error) {
+	config, err := loadDefaultConfig(configPath)
+	if errors.Is(err, errNoConfig) {
+		return PopupConfig{Terminal: "alacritty", Columns: 100, Lines: 30}, nil, nil
+	}
+	if err != nil {
+		return PopupConfig{}, nil, err
+	}
+	return config.Popup, config.Terminals, nil
+}
+
+// screenSize returns the size of the main screen in points
+func screenSize() (int, int) {
+	out, err := exec.Command("osascript", "-e", `tell application "Finder" to get bounds of window of desktop`).Output()
+	if err == nil {
+		bounds := strings.Split(strings.TrimSpace(string(out)), ", ")
+		if len(bounds) == 4 {
+			width, errW := strconv.Atoi(bounds[2])
+			height, errH := strconv.Atoi(bounds[3])
+			if errW == nil && errH == nil {
+				return width, height
+			}
+		}
+	}
+	return 1440, 900
+}
+
+// openPopup runs the command in a centered window of the terminal, through
+// the terminal's popup template
+func openPopup(popup PopupConfig, terminal TerminalConfig, command string) error {
+	if terminal.Popup == "" {
+		return fmt.Errorf("terminal %s has no popup template", popup.Terminal)
+	}
+
+	// Window size in points, estimated from the default font cell size
+	width := popup.Columns*7 + 20
+	height := popup.Lines*16 + 40
+	screenWidth, screenHeight := screenSize()
+	x := max((screenWidth-width)/2, 0)
+	y := max((screenHeight-height)/2, 0)
+
+	line := shellJoin("/bin/sh", "-c", command)
+	script := expandTerminalTemplate(terminal.Popup, line,
+		"{columns}", strconv.Itoa(popup.Columns), "{lines}", strconv.Itoa(popup.Lines),
+		"{x}", strconv.Itoa(x), "{y}", strconv.Itoa(y),
+		"{right}", strconv.Itoa(x+width), "{bottom}", strconv.Itoa(y+height))
+	cmd := exec.Command("/bin/sh", "-c", script)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("failed to open popup: %s", msg)
+		}
+		return fmt.Errorf("failed to open popup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	relinkOutputPath string
+	relinkNoBackup   bool
+	relinkDryRun     bool
+)
+
+var relinkCmd = &cobra.Command{
+	Use:   "relink",
+	Short: "Point karabiner.json at the current karabingen binary",
+	Long: `Generated rules (tmux jump, aliases, ...) run karabingen by its absolute path at
+generation time. After the binary moved, e.g. a Homebrew upgrade changed the
+Cellar path, relink rewrites those shell commands to the running binary without
+regenerating the configuration.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filePath, err := resolveOutputPath(relinkOutputPath)
+		if err != nil {
+			return err
+		}
+		return relinkKarabinerConfig(filePath, relinkNoBackup, relinkDryRun)
+	},
+}
+
+func init() {
+	relinkCmd.Flags().StringVarP(&relinkOutputPath, "output", "o", "", "Path to karabiner.json file")
+	relinkCmd.Flags().BoolVar(&relinkNoBackup, "no-backup", false, "Skip creating backup of existing karabiner.json file")
+	relinkCmd.Flags().BoolVar(&relinkDryRun, "dry-run", false, "Only print the paths that would be replaced")
+}
+
+// karabingenPathPattern matches an absolute karabingen binary path in a shell
+// command, either bare or quoted
+var karabingenPathPattern = regexp.MustCompile(`(?:^|[\s'"])(/[^\s'"]*/karabingen)(?:[\s'"]|$)`)
+
+// embeddedExecutables returns the sorted karabingen paths the shell commands
+// of a karabiner.json run
+func embeddedExecutables(data []byte) ([]string, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse karabiner.json: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var walk func(node any)
+	walk = func(node any) {
+		switch node := node.(type) {
+		case map[string]any:
+			for key, value := range node {
+				if command, ok := value.(string); ok && key == "shell_command" {
+					for _, match := range karabingenPathPattern.FindAllStringSubmatch(command, -1) {
+						seen[match[1]] = true
+					}
+					continue
+				}
+				walk(value)
+			}
+		case []any:
+			for _, value := range node {
+				walk(value)
+			}
+		}
+	}
+	walk(root)
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// relinkKarabinerConfig replaces other karabingen paths in the shell commands
+// of karabiner.json with the running binary. The file is edited in place to
+// keep everything else as is.
+func relinkKarabinerConfig(filePath string, noBackup, dryRun bool) error {
+	executable, err := karabingenExecutable()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	paths, err := embeddedExecutables(data)
+	if err != nil {
+		return err
+	}
+
+	// Paths are compared and replaced as they appear inside JSON strings
+	newPath, err := json.Marshal(executable)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	newPath = newPath[1 : len(newPath)-1]
+
+	replaced := 0
+	for _, path := range paths {
+		if path == executable {
+			continue
+		}
+		fmt.Printf("%s -> %s\n", path, executable)
+		oldPath, err := json.Marshal(path)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		data = bytes.ReplaceAll(data, oldPath[1:len(oldPath)-1], newPath)
+		replaced++
+	}
+	if replaced == 0 {
+		fmt.Println("Already linked to", executable)
+		return nil
+	}
+	if dryRun {
+		return nil
+	}
+
+	if !noBackup {
+		backupKarabinerConfig(filePath)
+	}
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Printf("Configuration written to: %s\n", filePath)
+	return nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// karabinerShellServerServices are the launchd labels of
+// karabiner_console_user_server, of Karabiner-Elements 15 and later first
+var karabinerShellServerServices = []string{
+	"org.pqrs.service.agent.karabiner_console_user_server",
+	"org.pqrs.karabiner.karabiner_console_user_server",
+}
+
+// reloadKarabiner makes Karabiner-Elements pick up a new karabiner.json by
+// restarting karabiner_console_user_server, or by selecting the selected
+// profile again with karabiner_cli when launchd doesn't know the service
+func reloadKarabiner(config *Config, karabinerConfig KarabinerConfig) error {
+	var failures []string
+	for _, service := range karabinerShellServerServices {
+		target := fmt.Sprintf("gui/%d/%s", os.Getuid(), service)
+		out, err := exec.Command("launchctl", "kickstart", "-k", target).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		failures = append(failures, strings.TrimSpace(string(out)+" "+err.Error()))
+	}
+
+	for _, profile := range karabinerConfig.Profiles {
+		if !profile.Selected {
+			continue
+		}
+		out, err := exec.Command(karabinerCLI(config), "--select-profile", profile.Name).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		failures = append(failures, strings.TrimSpace(string(out)+" "+err.Error()))
+	}
+	return fmt.Errorf("failed to reload Karabiner-Elements: %s", strings.Join(failures, "; "))
+}
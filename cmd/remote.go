@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// isRemoteConfig reports whether the config path is an http(s) URL
+func isRemoteConfig(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// fetchRemoteConfig downloads a hosted config. A "#sha256=<hex>" fragment pins
+// the expected checksum, so a changed upstream file is rejected instead of applied.
+// Plain http URLs must be pinned, since anyone on the way could change the
+// shell commands of the config otherwise.
+func fetchRemoteConfig(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config url: %w", err)
+	}
+
+	var checksum string
+	if u.Fragment != "" {
+		var ok bool
+		checksum, ok = strings.CutPrefix(u.Fragment, "sha256=")
+		if !ok {
+			return nil, fmt.Errorf("unsupported config url fragment %q, expected sha256=<hex>", u.Fragment)
+		}
+		u.Fragment = ""
+	}
+	if u.Scheme == "http" && checksum == "" {
+		return nil, fmt.Errorf("refusing unpinned http url %s, use https or add #sha256=<hex>", u)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch config: %s returned %s", u, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if checksum != "" {
+		sum := sha256.Sum256(data)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, checksum) {
+			return nil, fmt.Errorf("config checksum mismatch: expected %s, got %s", checksum, actual)
+		}
+	}
+
+	return data, nil
+}
@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"encoding/json"
+	"os"
+	"runtime/debug"
+
 	"github.com/spf13/cobra"
 )
 
+// jsonOutput makes the commands that print results print JSON instead, for
+// scripts
+var jsonOutput bool
+
 var rootCmd = &cobra.Command{
 	Use:   "karabingen",
 	Short: "CLI tool to generate Karabiner configuration",
@@ -16,10 +24,28 @@ var tmuxCmd = &cobra.Command{
 	Long:  `Commands for managing tmux sessions and bookmarks.`,
 }
 
-var safariCmd = &cobra.Command{
-	Use:   "safari",
-	Short: "Safari browser utilities",
-	Long:  `Commands for managing Safari tabs and windows.`,
+var browserCmd = &cobra.Command{
+	Use:     "browser",
+	Aliases: []string{"safari"},
+	Short:   "Browser utilities",
+	Long: `Commands for managing browser tabs and windows.
+"safari" is kept as an alias of "browser", which defaults to Safari.`,
+}
+
+// SetVersion sets the version reported by --version and recorded in git commits
+func SetVersion(version string) {
+	// Binaries installed with "go install" carry the module version instead
+	if info, ok := debug.ReadBuildInfo(); ok && version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	rootCmd.Version = version
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
 }
 
 func Execute() error {
@@ -27,19 +53,88 @@ func Execute() error {
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print JSON instead of text (tmux list, tmux stats, keys list, lint, validate, diff, doctor)")
+
 	// Add generate command directly to root
 	rootCmd.AddCommand(generateCmd)
 
+	// Add edit command for the edit-and-regenerate workflow
+	rootCmd.AddCommand(editCmd)
+
+	// Add diff command for comparing karabiner.json versions
+	rootCmd.AddCommand(diffCmd)
+
+	// Add undo and redo commands for restoring backups
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(redoCmd)
+
+	// Add import command for adopting an existing karabiner.json
+	rootCmd.AddCommand(importCmd)
+
+	// Add config parent command
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(showConfigCmd)
+
+	// Add keys parent command
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(updateKeysCmd)
+	keysCmd.AddCommand(listKeysCmd)
+
+	// Add rule parent command
+	rootCmd.AddCommand(ruleCmd)
+	ruleCmd.AddCommand(showRuleCmd)
+
+	// Add popup command for terminal pickers
+	rootCmd.AddCommand(popupCmd)
+
+	// Add switcher command for the "go to anything" picker
+	rootCmd.AddCommand(switcherCmd)
+
+	// Add apps parent command
+	rootCmd.AddCommand(appsCmd)
+	appsCmd.AddCommand(frontmostAppsCmd)
+
+	// Add schedule parent command
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(tickScheduleCmd)
+	scheduleCmd.AddCommand(installScheduleCmd)
+
+	// Add share and add commands for exchanging layer presets
+	rootCmd.AddCommand(shareCmd)
+	rootCmd.AddCommand(addCmd)
+
+	// Add lint command for unreachable bindings
+	rootCmd.AddCommand(lintCmd)
+
+	// Add relink command for moved binaries
+	rootCmd.AddCommand(relinkCmd)
+
+	// Add validate command for checking a config before generating
+	rootCmd.AddCommand(validateCmd)
+
+	// Add doctor command for setup checks
+	rootCmd.AddCommand(doctorCmd)
+
+	// Add debug parent command
+	rootCmd.AddCommand(debugCmd)
+	debugCmd.AddCommand(eventsDebugCmd)
+	debugCmd.AddCommand(varsDebugCmd)
+
+	// Add run command for config aliases
+	rootCmd.AddCommand(runCmd)
+
 	// Add tmux parent command
 	rootCmd.AddCommand(tmuxCmd)
 
 	// Add tmux subcommands
 	tmuxCmd.AddCommand(switchTmuxCmd)
 	tmuxCmd.AddCommand(bookmarkTmuxCmd)
+	tmuxCmd.AddCommand(listTmuxCmd)
+	tmuxCmd.AddCommand(statsTmuxCmd)
 
-	// Add safari parent command
-	rootCmd.AddCommand(safariCmd)
+	// Add browser parent command
+	rootCmd.AddCommand(browserCmd)
 
-	// Add safari subcommands
-	safariCmd.AddCommand(switchSafariCmd)
+	// Add browser subcommands
+	browserCmd.AddCommand(switchBrowserCmd)
 }
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ruleConfigPath string
+	ruleAsYAML     bool
+	ruleAsJSON     bool
+)
+
+var ruleCmd = &cobra.Command{
+	Use:   "rule",
+	Short: "Inspect generated rules",
+	Long:  `Commands for inspecting the rules generated from the YAML configuration.`,
+}
+
+var showRuleCmd = &cobra.Command{
+	Use:   "show <preset>",
+	Short: "Print the rules a preset generates",
+	Long: `Print the exact rules a preset generates with the current config, e.g. to copy
+one rule into Karabiner's UI or a bug report. The preset is the config section
+name, like "leader", "hjkl", "tmux_jump", "symbols_layer" or "layers", or the
+name of a rule group.
+Without --config, uses $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showRule(ruleConfigPath, args[0], ruleAsYAML)
+	},
+}
+
+func init() {
+	showRuleCmd.Flags().StringVar(&ruleConfigPath, "config", "", "Path to YAML config file")
+	showRuleCmd.Flags().BoolVar(&ruleAsJSON, "json", false, "Print as JSON (default)")
+	showRuleCmd.Flags().BoolVar(&ruleAsYAML, "yaml", false, "Print as YAML")
+	showRuleCmd.MarkFlagsMutuallyExclusive("json", "yaml")
+}
+
+func showRule(configPath, preset string, asYAML bool) error {
+	config, err := loadDefaultConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	presetRules, err := createRules(config)
+	if err != nil {
+		return err
+	}
+
+	rules := []Rule{}
+	presets := []string{}
+	for _, generated := range presetRules {
+		if generated.preset == preset {
+			rules = append(rules, generated.rule)
+		}
+		if len(presets) == 0 || presets[len(presets)-1] != generated.preset {
+			presets = append(presets, generated.preset)
+		}
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("preset %q generates no rules with this config, available: %s", preset, strings.Join(presets, ", "))
+	}
+
+	if asYAML {
+		// JSON is valid YAML, so parsing it keeps Karabiner's field names and order
+		data, err := json.Marshal(rules)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return err
+		}
+		clearNodeStyle(&node)
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+		return encoder.Close()
+	}
+
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// clearNodeStyle switches JSON's flow style and quoting to block style YAML
+func clearNodeStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearNodeStyle(child)
+	}
+}
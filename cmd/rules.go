@@ -1,58 +1,148 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 )
 
-func createHyperKeyRule(hyperKey string) Rule {
+// hyperModifiers are held by the hyper key with hyper.modifiers
+var hyperModifiers = []string{"left_command", "left_control", "left_option"}
+
+func createHyperKeyRule(hyperKey string, hyperConfig HyperConfig, capsLock CapsLockConfig) (Rule, error) {
+	hyper := Manipulator{
+		Type:        "basic",
+		Description: fmt.Sprintf("%s -> Hyper Key", hyperKey),
+		From: From{
+			KeyCode: hyperKey,
+		},
+		To: []To{
+			{SetVariable: &SetVariable{Name: "hyper", Value: 1}},
+		},
+		ToAfterKeyUp: []To{
+			{SetVariable: &SetVariable{Name: "hyper", Value: 0}},
+		},
+	}
+	if boolValue(hyperConfig.Modifiers, false) {
+		hyper.To = []To{{KeyCode: "left_shift", Modifiers: hyperModifiers}}
+		hyper.ToAfterKeyUp = nil
+	}
+
+	switch hyperConfig.Tap {
+	case "none":
+	case "escape", "caps_lock":
+		hyper.ToIfAlone = []To{{KeyCode: hyperConfig.Tap}}
+	default:
+		key, modifiers, err := parseKeyChord(hyperConfig.Tap)
+		if err != nil {
+			return Rule{}, fmt.Errorf("hyper.tap: %w", err)
+		}
+		if err := validateKeyCode("key_code", key); err != nil {
+			return Rule{}, fmt.Errorf("hyper.tap: %w", err)
+		}
+		hyper.ToIfAlone = []To{{KeyCode: key, Modifiers: modifiers}}
+	}
+
+	var manipulators []Manipulator
+	if hyperKey == "caps_lock" {
+		hyper = holdCapsLock(hyper, capsLock)
+		manipulators = append(manipulators, shiftCapsLock(capsLock)...)
+	}
+	if hyperConfig.TapTimeoutMs > 0 && hyper.ToIfAlone != nil {
+		if hyper.Parameters == nil {
+			hyper.Parameters = &Parameters{}
+		}
+		hyper.Parameters.BasicToIfAloneTimeoutMilliseconds = hyperConfig.TapTimeoutMs
+	}
 	return Rule{
-		Description: fmt.Sprintf("Hyper Key (%s)", hyperKey),
-		Manipulators: []Manipulator{
-			{
-				Type:        "basic",
-				Description: fmt.Sprintf("%s -> Hyper Key", hyperKey),
-				From: From{
-					KeyCode: hyperKey,
-				},
-				To: []To{
-					{SetVariable: &SetVariable{Name: "hyper", Value: 1}},
-				},
-				ToAfterKeyUp: []To{
-					{SetVariable: &SetVariable{Name: "hyper", Value: 0}},
-				},
-				ToIfAlone: []To{
-					{KeyCode: "escape"},
-				},
+		Description:  fmt.Sprintf("Hyper Key (%s)", hyperKey),
+		Manipulators: append(manipulators, hyper),
+	}, nil
+}
+
+// releaseHoldLayers resets the variables of the hold layers when the hyper key
+// goes up, so a layer can't stay on without its key held
+func releaseHoldLayers(rule Rule, layers []LayerConfig) Rule {
+	var resets []To
+	for _, layer := range layers {
+		if layer.Mode == "" || layer.Mode == "hold" {
+			resets = append(resets, To{SetVariable: &SetVariable{Name: fmt.Sprintf("hyper_sublayer_%s", layer.Key), Value: 0}})
+		}
+	}
+	if len(resets) == 0 {
+		return rule
+	}
+
+	manipulators := make([]Manipulator, len(rule.Manipulators))
+	for i, m := range rule.Manipulators {
+		if len(m.To) > 0 && m.To[0].SetVariable != nil && m.To[0].SetVariable.Name == "hyper" {
+			m.ToAfterKeyUp = append(slices.Clone(m.ToAfterKeyUp), resets...)
+		}
+		manipulators[i] = m
+	}
+	rule.Manipulators = manipulators
+	return rule
+}
+
+func createHHKBModeRule(capsLock CapsLockConfig) Rule {
+	control := Manipulator{
+		Type:        "basic",
+		Description: "Caps Lock -> Left Control",
+		From: From{
+			KeyCode: "caps_lock",
+			Modifiers: &Modifiers{
+				Optional: []string{"any"},
 			},
 		},
+		To: []To{
+			{KeyCode: "left_control"},
+		},
+	}
+	return Rule{
+		Description:  "HHKB Mode (Caps Lock -> Left Control)",
+		Manipulators: append(shiftCapsLock(capsLock), holdCapsLock(control, capsLock)),
 	}
 }
 
-func createHHKBModeRule() Rule {
-	return Rule{
-		Description: "HHKB Mode (Caps Lock -> Left Control)",
-		Manipulators: []Manipulator{
-			{
-				Type:        "basic",
-				Description: "Caps Lock -> Left Control",
-				From: From{
-					KeyCode: "caps_lock",
-					Modifiers: &Modifiers{
-						Optional: []string{"any"},
-					},
-				},
-				To: []To{
-					{KeyCode: "left_control"},
+// shiftCapsLock keeps the real caps lock on shift+caps_lock once caps_lock is remapped
+func shiftCapsLock(capsLock CapsLockConfig) []Manipulator {
+	if !boolValue(capsLock.ShiftToggle, false) {
+		return nil
+	}
+	return []Manipulator{
+		{
+			Type:        "basic",
+			Description: "Shift + Caps Lock -> Caps Lock",
+			From: From{
+				KeyCode: "caps_lock",
+				Modifiers: &Modifiers{
+					Mandatory: []string{"shift"},
 				},
 			},
+			To: []To{
+				{KeyCode: "caps_lock"},
+			},
 		},
 	}
 }
 
+// holdCapsLock makes holding the remapped caps_lock alone toggle the real caps lock
+func holdCapsLock(m Manipulator, capsLock CapsLockConfig) Manipulator {
+	if capsLock.HoldToggleMs <= 0 {
+		return m
+	}
+	m.ToIfHeldDown = []To{{KeyCode: "caps_lock"}}
+	m.Parameters = &Parameters{BasicToIfHeldDownThresholdMilliseconds: capsLock.HoldToggleMs}
+	return m
+}
+
 func createDisableLeftCtrlRule() Rule {
 	return Rule{
 		Description: "Disable Left Control",
@@ -94,15 +184,45 @@ func createDisableCommandTabRule() Rule {
 	}
 }
 
-func createFixG502Rule(safariOnly bool, backButton, forwardButton string) Rule {
-	var conditions []Condition
-	if safariOnly {
-		conditions = []Condition{
+func createBothShiftsCapsLockRule() Rule {
+	return Rule{
+		Description: "Both Shift Keys -> Caps Lock",
+		Manipulators: []Manipulator{
 			{
-				Type:              "frontmost_application_if",
-				BundleIdentifiers: []string{"^com\\.apple\\.Safari$"},
+				Type:        "basic",
+				Description: "Left Shift + Right Shift -> Caps Lock",
+				From: From{
+					Simultaneous: []SimultaneousKey{
+						{KeyCode: "left_shift"},
+						{KeyCode: "right_shift"},
+					},
+					SimultaneousOptions: &SimultaneousOptions{KeyDownOrder: "insensitive"},
+					Modifiers: &Modifiers{
+						Optional: []string{"caps_lock"},
+					},
+				},
+				To: []To{
+					{KeyCode: "caps_lock"},
+				},
 			},
-		}
+		},
+	}
+}
+
+func createFixG502Rule(safariOnly bool, backButton, forwardButton string) Rule {
+	// Keyboards may report button events too
+	isPointingDevice := true
+	conditions := []Condition{
+		{
+			Type:        "device_if",
+			Identifiers: []DeviceIdentifier{{IsPointingDevice: &isPointingDevice}},
+		},
+	}
+	if safariOnly {
+		conditions = append(conditions, Condition{
+			Type:              "frontmost_application_if",
+			BundleIdentifiers: []string{"^com\\.apple\\.Safari$"},
+		})
 	}
 
 	return Rule{
@@ -134,47 +254,92 @@ func createFixG502Rule(safariOnly bool, backButton, forwardButton string) Rule {
 	}
 }
 
-func createOptionKeybindingRule(key string, binding KeyBinding) Rule {
-	var to To
-
-	switch binding.Type {
-	case "app":
-		to = To{
-			SoftwareFunction: &SoftwareFunction{
-				OpenApplication: &OpenApplication{
-					FilePath: binding.Val,
-				},
-			},
+// createBindingTos converts a binding into its To events. A binding with actions
+// sends them in order; actions without a type inherit the binding's type.
+func createBindingTos(config *Config, binding KeyBinding) ([]To, error) {
+	if len(binding.Actions) == 0 {
+		to, err := createBindingTo(config, binding)
+		if err != nil {
+			return nil, err
 		}
-	case "web":
-		to = To{
-			ShellCommand: fmt.Sprintf("open %s", binding.Val),
+		return []To{to}, nil
+	}
+
+	tos := []To{}
+	for i, action := range binding.Actions {
+		if action.Type == "" {
+			action.Type = binding.Type
 		}
-	case "shell":
-		to = To{
-			ShellCommand: binding.Val,
+		actionTos, err := createBindingTos(config, action)
+		if err != nil {
+			return nil, fmt.Errorf("action %d: %w", i+1, err)
 		}
+		tos = append(tos, actionTos...)
 	}
+	return tos, nil
+}
+
+// iconLabel puts an optional icon before a description
+func iconLabel(icon, description string) string {
+	return strings.TrimSpace(icon + " " + description)
+}
+
+// bindingLabel describes what a binding does, for rule descriptions
+func bindingLabel(binding KeyBinding) string {
+	if binding.Description != "" || binding.Icon != "" {
+		return iconLabel(binding.Icon, binding.Description)
+	}
+	if len(binding.Actions) == 0 {
+		return binding.Val
+	}
+	labels := make([]string, len(binding.Actions))
+	for i, action := range binding.Actions {
+		labels[i] = bindingLabel(action)
+	}
+	return strings.Join(labels, ", ")
+}
+
+// createBindingTo generates the event of a binding through its action
+func createBindingTo(config *Config, binding KeyBinding) (To, error) {
+	// System presets work with every binding type
+	if name, ok := strings.CutPrefix(binding.Val, "sys:"); ok {
+		return createSystemActionTo(name)
+	}
+
+	a, err := lookupAction(binding.Type)
+	if err != nil {
+		return To{}, err
+	}
+	return a.Generate(config, binding)
+}
+
+func createOptionKeybindingRule(config *Config, key string, binding KeyBinding) (Rule, error) {
+	tos, err := createBindingTos(config, binding)
+	if err != nil {
+		return Rule{}, fmt.Errorf("option+%s: %w", key, err)
+	}
+
+	optional := config.optionalModifiers([]string{"caps_lock"}, binding.Optional)
 
 	return Rule{
-		Description: "Open TBD",
+		Description: fmt.Sprintf("Option+%s: %s", key, bindingLabel(binding)),
 		Manipulators: []Manipulator{
 			{
 				Type: "basic",
 				From: From{
 					KeyCode: key,
 					Modifiers: &Modifiers{
-						Mandatory: []string{"left_option"},
-						Optional:  []string{"caps_lock"},
+						Mandatory: []string{config.Keybindings.OptionModifier},
+						Optional:  optional,
 					},
 				},
-				To: []To{to},
+				To: tos,
 			},
 		},
-	}
+	}, nil
 }
 
-func createHJKLRule() Rule {
+func createHJKLRule(modifier string, optional []string) Rule {
 	return Rule{
 		Description: "Map Option + H/J/K/L to Arrow Keys",
 		Manipulators: []Manipulator{
@@ -182,7 +347,7 @@ func createHJKLRule() Rule {
 				Type: "basic",
 				From: From{
 					KeyCode:   "h",
-					Modifiers: &Modifiers{Mandatory: []string{"option"}},
+					Modifiers: &Modifiers{Mandatory: []string{modifier}, Optional: optional},
 				},
 				To: []To{{KeyCode: "left_arrow"}},
 			},
@@ -190,7 +355,7 @@ func createHJKLRule() Rule {
 				Type: "basic",
 				From: From{
 					KeyCode:   "j",
-					Modifiers: &Modifiers{Mandatory: []string{"option"}},
+					Modifiers: &Modifiers{Mandatory: []string{modifier}, Optional: optional},
 				},
 				To: []To{{KeyCode: "down_arrow"}},
 			},
@@ -198,7 +363,7 @@ func createHJKLRule() Rule {
 				Type: "basic",
 				From: From{
 					KeyCode:   "k",
-					Modifiers: &Modifiers{Mandatory: []string{"option"}},
+					Modifiers: &Modifiers{Mandatory: []string{modifier}, Optional: optional},
 				},
 				To: []To{{KeyCode: "up_arrow"}},
 			},
@@ -206,7 +371,7 @@ func createHJKLRule() Rule {
 				Type: "basic",
 				From: From{
 					KeyCode:   "l",
-					Modifiers: &Modifiers{Mandatory: []string{"option"}},
+					Modifiers: &Modifiers{Mandatory: []string{modifier}, Optional: optional},
 				},
 				To: []To{{KeyCode: "right_arrow"}},
 			},
@@ -214,7 +379,7 @@ func createHJKLRule() Rule {
 				Type: "basic",
 				From: From{
 					KeyCode:   "m",
-					Modifiers: &Modifiers{Mandatory: []string{"option"}},
+					Modifiers: &Modifiers{Mandatory: []string{modifier}, Optional: optional},
 				},
 				To: []To{{KeyCode: "return_or_enter"}},
 			},
@@ -222,28 +387,250 @@ func createHJKLRule() Rule {
 	}
 }
 
-func createTmuxJumpRule(config *Config) (Rule, error) {
-	tmuxConfig := config.TmuxJump
+// exceptApps makes the rule skip the apps with the given bundle identifiers,
+// which may also be regular expressions starting with "^"
+func exceptApps(rule Rule, apps []string) Rule {
+	if len(apps) == 0 {
+		return rule
+	}
+	patterns := make([]string, len(apps))
+	for i, app := range apps {
+		if strings.HasPrefix(app, "^") {
+			patterns[i] = app
+		} else {
+			patterns[i] = "^" + regexp.QuoteMeta(app) + "$"
+		}
+	}
 
-	// Get the path to karabingen executable
+	manipulators := make([]Manipulator, len(rule.Manipulators))
+	for i, m := range rule.Manipulators {
+		m.Conditions = append(slices.Clone(m.Conditions), Condition{Type: "frontmost_application_unless", BundleIdentifiers: patterns})
+		manipulators[i] = m
+	}
+	rule.Manipulators = manipulators
+	return rule
+}
+
+// appleScriptString quotes a value as an AppleScript string literal
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// secretPattern matches "{keychain:<item>}" and "{env:<name>}" secret placeholders
+var secretPattern = regexp.MustCompile(`\{(keychain|env):([^}]*)\}`)
+
+// envNamePattern matches the names of environment variables
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// keychainCommand reads a generic password from the login keychain
+func keychainCommand(item string) string {
+	return fmt.Sprintf("security find-generic-password -w -s %s", shellQuote(item))
+}
+
+// secretExpansion returns the double-quoted shell expansion reading a secret
+// when the command runs: a Keychain lookup, or an environment variable
+func secretExpansion(kind, name, s string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty %s name in %q", kind, s)
+	}
+	if kind == "env" {
+		if !envNamePattern.MatchString(name) {
+			return "", fmt.Errorf("invalid environment variable %q in %q", name, s)
+		}
+		return `"${` + name + `}"`, nil
+	}
+	return `"$(` + keychainCommand(name) + `)"`, nil
+}
+
+// shellQuoteSecrets quotes a value like shellQuote, except that secret
+// placeholders are read when the command runs, so secrets never end up in
+// karabiner.json
+func shellQuoteSecrets(s string) (string, error) {
+	var quoted strings.Builder
+	last := 0
+	for _, match := range secretPattern.FindAllStringSubmatchIndex(s, -1) {
+		expansion, err := secretExpansion(s[match[2]:match[3]], s[match[4]:match[5]], s)
+		if err != nil {
+			return "", err
+		}
+		if match[0] > last {
+			quoted.WriteString(shellQuote(s[last:match[0]]))
+		}
+		quoted.WriteString(expansion)
+		last = match[1]
+	}
+	if last < len(s) || last == 0 {
+		quoted.WriteString(shellQuote(s[last:]))
+	}
+	return quoted.String(), nil
+}
+
+// expandSecrets replaces the secret placeholders in a shell command with
+// quoted lookups. The shell expands nothing inside single quotes, so a
+// placeholder there is an error.
+func expandSecrets(command string) (string, error) {
+	var expanded strings.Builder
+	last := 0
+	for _, match := range secretPattern.FindAllStringSubmatchIndex(command, -1) {
+		if quote, _ := shellQuoteState(command[:match[0]]); quote == '\'' {
+			return "", fmt.Errorf("%s is inside single quotes in %q, where the shell can't expand it", command[match[0]:match[1]], command)
+		}
+		expansion, err := secretExpansion(command[match[2]:match[3]], command[match[4]:match[5]], command)
+		if err != nil {
+			return "", err
+		}
+		expanded.WriteString(command[last:match[0]])
+		expanded.WriteString(expansion)
+		last = match[1]
+	}
+	expanded.WriteString(command[last:])
+	return expanded.String(), nil
+}
+
+// createHTTPCommand builds a curl command sending the binding's request
+func createHTTPCommand(binding KeyBinding) (string, error) {
+	if binding.Val == "" {
+		return "", fmt.Errorf("http binding has no url")
+	}
+
+	method := strings.ToUpper(binding.Method)
+	if method == "" {
+		method = "GET"
+		if binding.Body != nil {
+			method = "POST"
+		}
+	}
+
+	command := fmt.Sprintf("curl -fsS --max-time 10 -X %s", method)
+
+	headers := make([]string, 0, len(binding.Headers))
+	for name := range binding.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		header, err := shellQuoteSecrets(fmt.Sprintf("%s: %s", name, binding.Headers[name]))
+		if err != nil {
+			return "", err
+		}
+		command += " -H " + header
+	}
+
+	if binding.Body != nil {
+		body, ok := binding.Body.(string)
+		if !ok {
+			data, err := json.Marshal(binding.Body)
+			if err != nil {
+				return "", fmt.Errorf("failed to encode http body: %w", err)
+			}
+			body = string(data)
+		}
+		quotedBody, err := shellQuoteSecrets(body)
+		if err != nil {
+			return "", err
+		}
+		command += fmt.Sprintf(" -H 'Content-Type: application/json' --data %s", quotedBody)
+	}
+	url, err := shellQuoteSecrets(binding.Val)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s >/dev/null", command, url), nil
+}
+
+// osascriptCommand builds a shell command running the given AppleScript lines
+func osascriptCommand(lines ...string) string {
+	command := "osascript"
+	for _, line := range lines {
+		command += " -e " + shellQuote(line)
+	}
+	return command
+}
+
+// findExecutable resolves a binary name to its full path via $PATH and common
+// install locations, returning the name as-is if it can't be found
+func findExecutable(name string) string {
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+
+	// Fallback to Homebrew (Apple Silicon, Intel), MacPorts and system locations
+	prefixes := []string{"/opt/homebrew/bin", "/usr/local/bin", "/opt/local/bin", "/usr/bin"}
+	if prefix := brewPrefix(); prefix != "" {
+		prefixes = append(prefixes, filepath.Join(prefix, "bin"))
+	}
+	for _, prefix := range prefixes {
+		path := filepath.Join(prefix, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return name
+}
+
+// brewPrefix returns the prefix of a Homebrew installed in a custom location,
+// or "" without brew in $PATH
+var brewPrefix = sync.OnceValue(func() string {
+	brew, err := exec.LookPath("brew")
+	if err != nil {
+		return ""
+	}
+	out, err := exec.Command(brew, "--prefix").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+})
+
+// karabingenExecutable returns the absolute path of the running karabingen binary
+func karabingenExecutable() (string, error) {
 	executable, err := os.Executable()
 	if err != nil {
-		return Rule{}, fmt.Errorf("failed to get executable path: %w", err)
+		return "", fmt.Errorf("failed to get executable path: %w", err)
 	}
 	executable, err = filepath.Abs(executable)
 	if err != nil {
-		return Rule{}, fmt.Errorf("failed to get absolute path: %w", err)
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return executable, nil
+}
+
+func createTmuxJumpRule(config *Config) (Rule, error) {
+	tmuxConfig := config.TmuxJump
+
+	// Get the path to karabingen executable
+	executable, err := karabingenExecutable()
+	if err != nil {
+		return Rule{}, err
 	}
 
 	manipulators := []Manipulator{}
 
 	// Create the base command
-	baseCmd := fmt.Sprintf("%s tmux switch --tmux %s --jumplist %s --terminal %s",
-		executable,
-		tmuxConfig.TmuxPath,
-		tmuxConfig.JumplistPath,
-		tmuxConfig.Terminal,
+	baseCmd := shellJoin(executable, "tmux", "switch",
+		"--jumplist", tmuxConfig.JumplistPath,
+		"--terminal", tmuxConfig.Terminal,
 	)
+	// Without a configured path, tmux switch looks tmux up when it runs
+	if tmuxPath := config.tmuxPath(); tmuxPath != "" {
+		baseCmd += " --tmux " + shellQuote(tmuxPath)
+	}
+	if tmuxConfig.Socket != "" {
+		baseCmd += " --socket " + shellQuote(tmuxConfig.Socket)
+	}
+	if boolValue(tmuxConfig.TrackUsage, false) {
+		baseCmd += " --track-usage"
+	}
+	// Custom terminals are read from the config when the key is pressed
+	if len(config.Terminals) > 0 {
+		baseCmd += " --config " + shellQuote(config.path)
+	}
+
+	terminal, err := lookupTerminal(config.Terminals, tmuxConfig.Terminal)
+	if err != nil {
+		return Rule{}, err
+	}
 
 	modifierNames := make([]string, len(tmuxConfig.Modifiers))
 	for i, mod := range tmuxConfig.Modifiers {
@@ -251,7 +638,7 @@ func createTmuxJumpRule(config *Config) (Rule, error) {
 	}
 	modStr := strings.Join(modifierNames, "+")
 
-	// 0 opens the tmuxjumplist file in a new terminal window
+	// The edit key (0 by default) opens the tmuxjumplist file in a new terminal window
 	// Find the editor executable with full path
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
@@ -259,24 +646,7 @@ func createTmuxJumpRule(config *Config) (Rule, error) {
 	}
 
 	// Try to find the full path to the editor
-	editorPath, err := exec.LookPath(editor)
-	if err != nil {
-		// Fallback to common locations
-		commonPaths := []string{
-			"/opt/homebrew/bin/" + editor,
-			"/usr/local/bin/" + editor,
-			"/usr/bin/" + editor,
-		}
-		for _, path := range commonPaths {
-			if _, err := os.Stat(path); err == nil {
-				editorPath = path
-				break
-			}
-		}
-		if editorPath == "" {
-			editorPath = editor // Use as-is if we can't find it
-		}
-	}
+	editorPath := findExecutable(editor)
 
 	// Expand tilde in jumplist path
 	jumplistPath := tmuxConfig.JumplistPath
@@ -287,45 +657,42 @@ func createTmuxJumpRule(config *Config) (Rule, error) {
 		}
 	}
 
-	var editCmd string
-	switch tmuxConfig.Terminal {
-	case "alacritty":
-		editCmd = fmt.Sprintf("open -a Alacritty -n --args -e %s %s", editorPath, jumplistPath)
-	case "ghostty":
-		editCmd = fmt.Sprintf("open -a Ghostty -n --args -e %s %s", editorPath, jumplistPath)
-	case "iterm2":
-		editCmd = fmt.Sprintf(`osascript -e 'tell application "iTerm" to create window with default profile command "%s %s"'`, editorPath, jumplistPath)
-	case "terminal":
-		editCmd = fmt.Sprintf(`osascript -e 'tell application "Terminal" to do script "%s %s"'`, editorPath, jumplistPath)
-	default:
-		editCmd = fmt.Sprintf("open -a Alacritty -n --args -e %s %s", editorPath, jumplistPath)
-	}
+	editCmd := expandTerminalTemplate(terminal.OpenNewWindow, shellWord(editorPath)+" "+shellQuote(jumplistPath))
 
-	manipulators = append(manipulators, Manipulator{
-		Type: "basic",
-		From: From{
-			KeyCode:   "0",
-			Modifiers: &Modifiers{Mandatory: tmuxConfig.Modifiers},
-		},
-		To: []To{
-			{ShellCommand: editCmd},
-		},
-		Description: fmt.Sprintf("%s+0 → edit tmuxjumplist", modStr),
-	})
+	if tmuxConfig.EditKey != "" {
+		manipulators = append(manipulators, Manipulator{
+			Type: "basic",
+			From: From{
+				KeyCode:   tmuxConfig.EditKey,
+				Modifiers: &Modifiers{Mandatory: tmuxConfig.Modifiers},
+			},
+			To: []To{
+				{ShellCommand: editCmd},
+			},
+			Description: fmt.Sprintf("%s+%s → edit tmuxjumplist", modStr, tmuxConfig.EditKey),
+		})
+	}
 
-	// 1-9 jump to tmux sessions
-	for i := 1; i <= 9; i++ {
-		digit := fmt.Sprintf("%d", i)
+	// Digits (1-9 by default) jump to tmux sessions
+	digitModifiers := append(append([]string{}, tmuxConfig.Modifiers...), tmuxConfig.DigitModifiers...)
+	digitModStr := modStr
+	for _, mod := range tmuxConfig.DigitModifiers {
+		digitModStr += "+" + strings.Title(mod)
+	}
+	for _, digit := range tmuxConfig.Digits {
+		if digit == tmuxConfig.EditKey && len(tmuxConfig.DigitModifiers) == 0 {
+			return Rule{}, fmt.Errorf("tmux_jump digit %s is also the edit_key", digit)
+		}
 		manipulators = append(manipulators, Manipulator{
 			Type: "basic",
 			From: From{
 				KeyCode:   digit,
-				Modifiers: &Modifiers{Mandatory: tmuxConfig.Modifiers},
+				Modifiers: &Modifiers{Mandatory: digitModifiers},
 			},
 			To: []To{
-				{ShellCommand: fmt.Sprintf("%s %s", baseCmd, digit)},
+				{ShellCommand: baseCmd + " " + shellWord(digit)},
 			},
-			Description: fmt.Sprintf("%s+%s → tmux session %s", modStr, digit, digit),
+			Description: fmt.Sprintf("%s+%s → tmux session %s", digitModStr, digit, digit),
 		})
 	}
 
@@ -338,7 +705,7 @@ func createTmuxJumpRule(config *Config) (Rule, error) {
 				Modifiers: &Modifiers{Mandatory: tmuxConfig.Modifiers},
 			},
 			To: []To{
-				{ShellCommand: fmt.Sprintf("%s %s", baseCmd, letter)},
+				{ShellCommand: baseCmd + " " + shellWord(letter)},
 			},
 			Description: fmt.Sprintf("%s+%s → tmux session %s", modStr, letter, letter),
 		})
@@ -350,7 +717,7 @@ func createTmuxJumpRule(config *Config) (Rule, error) {
 	}, nil
 }
 
-func createLayerRules(layers []LayerConfig) []Rule {
+func createLayerRules(config *Config, layers []LayerConfig) ([]Rule, error) {
 	rules := []Rule{}
 	allLayerKeys := make([]string, len(layers))
 	for i, layer := range layers {
@@ -380,71 +747,144 @@ func createLayerRules(layers []LayerConfig) []Rule {
 			otherLayerConditions...,
 		)
 
-		// Toggle manipulator
-		toggleManipulator := Manipulator{
-			Type:        "basic",
-			Description: fmt.Sprintf("Toggle Hyper sublayer %s", key),
-			From: From{
-				KeyCode: key,
-			},
-			To: []To{
-				{SetVariable: &SetVariable{
-					Name:  fmt.Sprintf("hyper_sublayer_%s", key),
-					Value: 1,
-				}},
-			},
-			ToAfterKeyUp: []To{
-				{SetVariable: &SetVariable{
-					Name:  fmt.Sprintf("hyper_sublayer_%s", key),
-					Value: 0,
-				}},
-			},
-			Conditions: toggleConditions,
+		variable := fmt.Sprintf("hyper_sublayer_%s", key)
+		var manipulators []Manipulator
+
+		description := fmt.Sprintf("Hyper Key sublayer \"%s\"", key)
+		if layer.Description != "" || layer.Icon != "" {
+			description = iconLabel(layer.Icon, layer.Description)
+		}
+
+		// Described toggle layers announce themselves
+		notification := layer.Notification
+		if notification == "" && layer.Mode == "toggle" && layer.Description != "" {
+			notification = description
+		}
+
+		layerOn := []To{{SetVariable: &SetVariable{Name: variable, Value: 1}}}
+		layerOff := []To{{SetVariable: &SetVariable{Name: variable, Value: 0}}}
+		if notification != "" {
+			layerOn = append(layerOn, To{SetNotificationMessage: &SetNotificationMessage{ID: variable, Text: notification}})
+			layerOff = append(layerOff, To{SetNotificationMessage: &SetNotificationMessage{ID: variable, Text: ""}})
 		}
 
-		manipulators := []Manipulator{toggleManipulator}
-
-		// Sub-key manipulators
-		for subkey, val := range subBindings {
-			var to To
-			if layerType == "app" {
-				to = To{
-					SoftwareFunction: &SoftwareFunction{
-						OpenApplication: &OpenApplication{
-							FilePath: val,
-						},
+		// Auto-timeout: every layer key press restarts the timer
+		var timeout *ToDelayedAction
+		var timeoutParameters *Parameters
+		if layer.TimeoutMs > 0 {
+			timeout = &ToDelayedAction{ToIfInvoked: layerOff}
+			timeoutParameters = &Parameters{BasicToDelayedActionDelayMilliseconds: layer.TimeoutMs}
+		}
+
+		switch layer.Mode {
+		case "", "hold":
+			// Toggle manipulator
+			manipulators = append(manipulators, Manipulator{
+				Type:        "basic",
+				Description: fmt.Sprintf("Toggle Hyper sublayer %s", key),
+				From: From{
+					KeyCode: key,
+				},
+				To: []To{
+					{SetVariable: &SetVariable{Name: variable, Value: 1}},
+				},
+				ToAfterKeyUp: []To{
+					{SetVariable: &SetVariable{Name: variable, Value: 0}},
+				},
+				ToDelayedAction: timeout,
+				Conditions:      toggleConditions,
+				Parameters:      timeoutParameters,
+			})
+		case "toggle":
+			// Latch manipulators: first press turns the layer on, second press turns it off
+			manipulators = append(manipulators,
+				Manipulator{
+					Type:        "basic",
+					Description: fmt.Sprintf("Latch Hyper sublayer %s off", key),
+					From: From{
+						KeyCode: key,
 					},
+					To: layerOff,
+					Conditions: []Condition{
+						{Type: "variable_if", Name: "hyper", Value: 1},
+						{Type: "variable_if", Name: variable, Value: 1},
+					},
+				},
+				Manipulator{
+					Type:        "basic",
+					Description: fmt.Sprintf("Latch Hyper sublayer %s on", key),
+					From: From{
+						KeyCode: key,
+					},
+					To:              layerOn,
+					ToDelayedAction: timeout,
+					Conditions:      toggleConditions,
+					Parameters:      timeoutParameters,
+				},
+			)
+		default:
+			return nil, fmt.Errorf("unknown mode %q for layer %s", layer.Mode, key)
+		}
+
+		// Sub-key manipulators, sorted for a stable output
+		subkeys := make([]string, 0, len(subBindings))
+		for subkey := range subBindings {
+			subkeys = append(subkeys, subkey)
+		}
+		sort.Strings(subkeys)
+		for _, subkey := range subkeys {
+			binding := subBindings[subkey]
+			if binding.Type == "" {
+				binding.Type = layerType
+			}
+			tos, err := createBindingTos(config, KeyBinding(binding))
+			if err != nil {
+				return nil, fmt.Errorf("layer %s key %s: %w", key, subkey, err)
+			}
+
+			// Sub keys may carry modifiers, e.g. "shift+h"
+			parts := strings.Split(subkey, "+")
+			optional := config.optionalModifiers(nil, binding.Optional, layer.Optional)
+			var modifiers *Modifiers
+			if len(parts) > 1 || optional != nil {
+				modifiers = &Modifiers{
+					Mandatory: parts[:len(parts)-1],
+					Optional:  optional,
 				}
-			} else if layerType == "web" {
-				to = To{
-					ShellCommand: fmt.Sprintf("open %s", val),
-				}
+			}
+
+			subDescription := "Open "
+			if binding.Description != "" || binding.Icon != "" {
+				subDescription = bindingLabel(KeyBinding(binding))
 			}
 
 			manipulators = append(manipulators, Manipulator{
 				Type:        "basic",
-				Description: "Open ",
+				Description: subDescription,
 				From: From{
-					KeyCode: subkey,
+					KeyCode:   parts[len(parts)-1],
+					Modifiers: modifiers,
 				},
-				To: []To{to},
+				To:              tos,
+				ToDelayedAction: timeout,
 				Conditions: []Condition{
 					{
 						Type:  "variable_if",
-						Name:  fmt.Sprintf("hyper_sublayer_%s", key),
+						Name:  variable,
 						Value: 1,
 					},
 				},
+				Parameters: timeoutParameters,
 			})
 		}
 
 		rules = append(rules, Rule{
-			Description:  fmt.Sprintf("Hyper Key sublayer \"%s\"", key),
+			Description:  description,
 			Manipulators: manipulators,
 		})
 	}
 
-	return rules
+	return rules, nil
 }
 
 func createSwitchTabsRule() Rule {
@@ -480,3 +920,295 @@ func createSwitchTabsRule() Rule {
 		},
 	}
 }
+
+func createDoubleModifierRule(config *Config, doubleModifier DoubleModifierConfig) (Rule, error) {
+	modifier := doubleModifier.Modifier
+	variable := fmt.Sprintf("double_%s", modifier)
+
+	actions, err := createBindingTos(config, doubleModifier.KeyBinding)
+	if err != nil {
+		return Rule{}, fmt.Errorf("double %s: %w", modifier, err)
+	}
+
+	delayMs := doubleModifier.DelayMs
+	if delayMs == 0 {
+		delayMs = 300
+	}
+
+	return Rule{
+		Description: fmt.Sprintf("Double %s → %s", modifier, bindingLabel(doubleModifier.KeyBinding)),
+		Manipulators: []Manipulator{
+			{
+				Type:        "basic",
+				Description: fmt.Sprintf("Second %s press → action", modifier),
+				From: From{
+					KeyCode:   modifier,
+					Modifiers: &Modifiers{Optional: []string{"any"}},
+				},
+				To: append([]To{
+					{SetVariable: &SetVariable{Name: variable, Value: 0}},
+				}, actions...),
+				Conditions: []Condition{
+					{Type: "variable_if", Name: variable, Value: 1},
+				},
+			},
+			{
+				Type:        "basic",
+				Description: fmt.Sprintf("First %s press → arm", modifier),
+				From: From{
+					KeyCode:   modifier,
+					Modifiers: &Modifiers{Optional: []string{"any"}},
+				},
+				To: []To{
+					{SetVariable: &SetVariable{Name: variable, Value: 1}},
+					{KeyCode: modifier},
+				},
+				ToDelayedAction: &ToDelayedAction{
+					ToIfInvoked:  []To{{SetVariable: &SetVariable{Name: variable, Value: 0}}},
+					ToIfCanceled: []To{{SetVariable: &SetVariable{Name: variable, Value: 0}}},
+				},
+				Parameters: &Parameters{
+					BasicToDelayedActionDelayMilliseconds: delayMs,
+				},
+			},
+		},
+	}, nil
+}
+
+func createHoldBindingRule(config *Config, holdBinding HoldBindingConfig) (Rule, error) {
+	key := holdBinding.Key
+
+	actions, err := createBindingTos(config, holdBinding.KeyBinding)
+	if err != nil {
+		return Rule{}, fmt.Errorf("hold %s: %w", key, err)
+	}
+
+	thresholdMs := holdBinding.ThresholdMs
+	if thresholdMs == 0 {
+		thresholdMs = 300
+	}
+
+	var modifiers *Modifiers
+	if optional := config.optionalModifiers(nil, holdBinding.Optional); optional != nil {
+		modifiers = &Modifiers{Optional: optional}
+	}
+
+	return Rule{
+		Description: fmt.Sprintf("Hold %s → %s", key, bindingLabel(holdBinding.KeyBinding)),
+		Manipulators: []Manipulator{
+			{
+				Type:        "basic",
+				Description: fmt.Sprintf("Hold %s for %dms → %s", key, thresholdMs, bindingLabel(holdBinding.KeyBinding)),
+				From: From{
+					KeyCode:   key,
+					Modifiers: modifiers,
+				},
+				ToIfAlone: []To{
+					{KeyCode: key},
+				},
+				ToIfHeldDown: actions,
+				Parameters: &Parameters{
+					BasicToIfAloneTimeoutMilliseconds:      thresholdMs,
+					BasicToIfHeldDownThresholdMilliseconds: thresholdMs,
+				},
+			},
+		},
+	}, nil
+}
+
+// modifierKeyCodes are the key codes accepted as a bare modifier in modifier_keys
+var modifierKeyCodes = map[string]bool{
+	"fn": true, "caps_lock": true,
+	"left_command": true, "right_command": true, "either_command": true,
+	"left_option": true, "right_option": true, "either_option": true,
+	"left_control": true, "right_control": true, "either_control": true,
+	"left_shift": true, "right_shift": true, "either_shift": true,
+}
+
+func createModifierKeyRule(config *Config, modifierKey ModifierKeyConfig) (Rule, error) {
+	key := modifierKey.Key
+	if !modifierKeyCodes[key] {
+		return Rule{}, fmt.Errorf("modifier key %q is not a modifier, use hold_bindings or keybindings for other keys", key)
+	}
+
+	actions, err := createBindingTos(config, modifierKey.KeyBinding)
+	if err != nil {
+		return Rule{}, fmt.Errorf("modifier key %s: %w", key, err)
+	}
+
+	from := From{KeyCode: key}
+	optional := config.optionalModifiers(nil, modifierKey.Optional)
+	if len(modifierKey.FromModifiers) > 0 || optional != nil {
+		from.Modifiers = &Modifiers{Mandatory: modifierKey.FromModifiers, Optional: optional}
+	}
+
+	name := key
+	for _, mod := range modifierKey.FromModifiers {
+		name = mod + "+" + name
+	}
+
+	manipulator := Manipulator{
+		Type: "basic",
+		From: from,
+	}
+	switch modifierKey.Mode {
+	case "", "tap":
+		// Held, the key still works as a modifier
+		manipulator.Description = fmt.Sprintf("Tap %s → %s", name, bindingLabel(modifierKey.KeyBinding))
+		manipulator.To = []To{{KeyCode: key}}
+		manipulator.ToIfAlone = actions
+	case "replace":
+		manipulator.Description = fmt.Sprintf("%s → %s", name, bindingLabel(modifierKey.KeyBinding))
+		manipulator.To = actions
+	default:
+		return Rule{}, fmt.Errorf("unknown mode %q for modifier key %s", modifierKey.Mode, key)
+	}
+
+	return Rule{
+		Description:  fmt.Sprintf("Modifier %s → %s", name, bindingLabel(modifierKey.KeyBinding)),
+		Manipulators: []Manipulator{manipulator},
+	}, nil
+}
+
+// createTriggerFrom converts a preset trigger into the From event and conditions of a manipulator.
+// hyper is used when the trigger does not say whether the hyperkey is required.
+func createTriggerFrom(trigger TriggerConfig, hyper bool) (From, []Condition) {
+	from := From{KeyCode: trigger.Key}
+	if len(trigger.Modifiers) > 0 {
+		from.Modifiers = &Modifiers{Mandatory: trigger.Modifiers}
+	}
+
+	var conditions []Condition
+	if boolValue(trigger.Hyper, hyper) {
+		conditions = append(conditions, Condition{Type: "variable_if", Name: "hyper", Value: 1})
+	}
+	return from, conditions
+}
+
+func createFunctionKeysToggleRule(toggle FunctionKeysToggleConfig) Rule {
+	const variable = "function_keys_mode"
+
+	from, conditions := createTriggerFrom(toggle.TriggerConfig, false)
+
+	functionOn := []To{{SetVariable: &SetVariable{Name: variable, Value: 1}}}
+	functionOff := []To{{SetVariable: &SetVariable{Name: variable, Value: 0}}}
+	if boolValue(toggle.Notification, false) {
+		functionOn = append(functionOn, To{SetNotificationMessage: &SetNotificationMessage{ID: variable, Text: "F1–F12: function keys"}})
+		functionOff = append(functionOff, To{SetNotificationMessage: &SetNotificationMessage{ID: variable, Text: ""}})
+	}
+
+	manipulators := []Manipulator{
+		{
+			Type:        "basic",
+			Description: "Switch F1–F12 to media keys",
+			From:        from,
+			To:          functionOff,
+			Conditions:  append([]Condition{{Type: "variable_if", Name: variable, Value: 1}}, conditions...),
+		},
+		{
+			Type:        "basic",
+			Description: "Switch F1–F12 to function keys",
+			From:        from,
+			To:          functionOn,
+			Conditions:  append([]Condition{{Type: "variable_unless", Name: variable, Value: 1}}, conditions...),
+		},
+	}
+
+	// While the variable is set, F-keys send fn+F (a real function key) and
+	// fn+F sends the plain key, which Karabiner turns into the media action
+	for i := 1; i <= 12; i++ {
+		key := fmt.Sprintf("f%d", i)
+		manipulators = append(manipulators,
+			Manipulator{
+				Type:        "basic",
+				Description: fmt.Sprintf("fn+%s → media key", strings.ToUpper(key)),
+				From: From{
+					KeyCode:   key,
+					Modifiers: &Modifiers{Mandatory: []string{"fn"}, Optional: []string{"any"}},
+				},
+				To:         []To{{KeyCode: key}},
+				Conditions: []Condition{{Type: "variable_if", Name: variable, Value: 1}},
+			},
+			Manipulator{
+				Type:        "basic",
+				Description: fmt.Sprintf("%s → function key", strings.ToUpper(key)),
+				From: From{
+					KeyCode:   key,
+					Modifiers: &Modifiers{Optional: []string{"any"}},
+				},
+				To:         []To{{KeyCode: key, Modifiers: []string{"fn"}}},
+				Conditions: []Condition{{Type: "variable_if", Name: variable, Value: 1}},
+			},
+		)
+	}
+
+	return Rule{
+		Description:  "Toggle F1–F12 between media and function keys",
+		Manipulators: manipulators,
+	}
+}
+
+// passwordManagers holds the quick access action of each supported password manager
+var passwordManagers = map[string]To{
+	// 1Password Quick Access global shortcut
+	"1password": {KeyCode: "spacebar", Modifiers: []string{"shift", "command"}},
+	// Bitwarden has no quick access window, bring up the app and focus search
+	"bitwarden": {ShellCommand: osascriptCommand(
+		`tell application "Bitwarden" to activate`,
+		`tell application "System Events" to keystroke "f" using command down`,
+	)},
+}
+
+func createPasswordManagerRule(passwordManager PasswordManagerConfig) (Rule, error) {
+	action, ok := passwordManagers[passwordManager.Manager]
+	if !ok {
+		return Rule{}, fmt.Errorf("unsupported password manager: %s (supported: 1password, bitwarden)", passwordManager.Manager)
+	}
+
+	from, conditions := createTriggerFrom(passwordManager.TriggerConfig, true)
+
+	return Rule{
+		Description: fmt.Sprintf("Password manager quick access (%s)", passwordManager.Manager),
+		Manipulators: []Manipulator{
+			{
+				Type:        "basic",
+				Description: fmt.Sprintf("%s quick access", passwordManager.Manager),
+				From:        from,
+				To:          []To{action},
+				Conditions:  conditions,
+			},
+		},
+	}, nil
+}
+
+// createRegenerateRule runs "karabingen generate" on the config, which posts a
+// notification when the rules changed or generation failed
+func createRegenerateRule(config *Config) (Rule, error) {
+	executable, err := karabingenExecutable()
+	if err != nil {
+		return Rule{}, err
+	}
+
+	from, conditions := createTriggerFrom(config.Regenerate.TriggerConfig, true)
+
+	// Regenerating writes where this generation did, e.g. a path given with -o
+	command := shellJoin(executable, "generate", config.path, "--notify")
+	if config.outputPath != "" {
+		command += " --output " + shellQuote(config.outputPath)
+	}
+
+	return Rule{
+		Description: "Regenerate karabiner.json",
+		Manipulators: []Manipulator{
+			{
+				Type:        "basic",
+				Description: fmt.Sprintf("%s → karabingen generate", from.KeyCode),
+				From:        from,
+				To: []To{
+					{ShellCommand: command},
+				},
+				Conditions: conditions,
+			},
+		},
+	}, nil
+}
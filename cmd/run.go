@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	runConfigPath string
+	runType       string
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <alias> | run --type <type> <val>",
+	Short: "Run a shell command alias or a binding from the config",
+	Long: `Run a command defined in the aliases section of the YAML configuration.
+Bindings of type "alias" call this command, so changing what a key does only
+requires editing the config, without regenerating karabiner.json.
+
+--type runs a binding of any type instead, as if its key was pressed, e.g.
+"karabingen run --type web https://example.com". Bindings sending key events
+only work from Karabiner.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		binding := KeyBinding{Type: "alias", Val: args[0]}
+		if runType != "" {
+			binding.Type = runType
+		}
+		if err := runBinding(runConfigPath, binding); err != nil {
+			// Triggered from Karabiner, so log the error for debugging
+			logError(err)
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	runCmd.Flags().StringVar(&runConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	runCmd.Flags().StringVar(&runType, "type", "", "Binding type to run the value as, instead of an alias")
+}
+
+func runBinding(configPath string, binding KeyBinding) error {
+	config, err := loadDefaultConfig(configPath)
+	if err != nil {
+		return err
+	}
+	return executeBinding(config, binding)
+}
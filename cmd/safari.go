@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// safariBackend drives Safari through AppleScript
+type safariBackend struct{}
+
+// AppleScript to list all Safari tabs as window id, tab index, minimized, name and url.
+// Windows without tabs (e.g. Settings) are skipped instead of failing the whole list,
+// and Safari is not launched when it isn't running.
+const listSafariTabsScript = `
+if application "Safari" is not running then return ""
+tell application "Safari"
+	set output to ""
+	set sep to ASCII character 31
+	repeat with w in windows
+		try
+			set windowID to id of w
+			set isMinimized to miniaturized of w
+			repeat with t from 1 to count tabs of w
+				set tabURL to URL of tab t of w
+				if tabURL is missing value then set tabURL to ""
+				set tabName to name of tab t of w
+				set output to output & windowID & sep & t & sep & isMinimized & sep & tabName & sep & tabURL & linefeed
+			end repeat
+		end try
+	end repeat
+	return output
+end tell
+`
+
+func (safariBackend) ListTabs() ([]browserTab, error) {
+	output, err := exec.Command("osascript", "-e", listSafariTabsScript).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Safari tabs (is Safari running?): %w", err)
+	}
+
+	tabs := []browserTab{}
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		parts := strings.Split(line, browserDelimiter)
+		if len(parts) < 5 {
+			continue
+		}
+		index, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		tabs = append(tabs, browserTab{
+			windowID:  parts[0],
+			index:     index,
+			minimized: parts[2] == "true",
+			name:      parts[3],
+			url:       parts[4],
+		})
+	}
+
+	// Safari's scripting dictionary has no pinned property
+	markPinnedTabs(tabs)
+	return tabs, nil
+}
+
+func (safariBackend) ActivateTab(tab browserTab, restore bool) error {
+	script := fmt.Sprintf(`tell application "Safari"
+	tell window id %s
+		if miniaturized then
+			if %t then
+				set miniaturized to false
+			end if
+		end if
+		set current tab to tab %d
+		set index to 1
+	end tell
+	activate
+end tell`, tab.windowID, restore, tab.index)
+	if err := exec.Command("osascript", "-e", script).Run(); err != nil {
+		return fmt.Errorf("failed to switch tab: %w", err)
+	}
+	return nil
+}
+
+func (safariBackend) CloseTab(tab browserTab) error {
+	script := fmt.Sprintf(`tell application "Safari" to close tab %d of window id %s`, tab.index, tab.windowID)
+	if err := exec.Command("osascript", "-e", script).Run(); err != nil {
+		return fmt.Errorf("failed to close tab: %w", err)
+	}
+	return nil
+}
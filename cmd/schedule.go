@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// karabinerCLIPath is where Karabiner-Elements installs its command line tool
+const karabinerCLIPath = "/Library/Application Support/org.pqrs/Karabiner-Elements/bin/karabiner_cli"
+
+// scheduleAgentLabel is the launchd label of the schedule tick job
+const scheduleAgentLabel = "io.github.fgazat.karabingen.schedule"
+
+var (
+	scheduleConfigPath string
+	scheduleInterval   int
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Time-based rule activation",
+	Long: `Commands for the schedules section. Scheduled rules only fire while the
+schedule's Karabiner variable is set, which "schedule tick" updates.`,
+}
+
+var tickScheduleCmd = &cobra.Command{
+	Use:   "tick",
+	Short: "Update the schedule variables in Karabiner",
+	Long: `Set the Karabiner variable of every schedule (schedule_<name>) to 1 inside its
+time window and 0 outside. Meant to run every minute, see "schedule install".`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadDefaultConfig(scheduleConfigPath)
+		if err != nil {
+			return err
+		}
+		return tickSchedules(config, time.Now())
+	},
+}
+
+var installScheduleCmd = &cobra.Command{
+	Use:          "install",
+	Short:        "Run schedule tick periodically with launchd",
+	Long:         `Install and load a launchd agent running "karabingen schedule tick" every --interval seconds.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadDefaultConfig(scheduleConfigPath)
+		if err != nil {
+			return err
+		}
+		return installScheduleAgent(config, scheduleInterval)
+	},
+}
+
+func init() {
+	tickScheduleCmd.Flags().StringVar(&scheduleConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	installScheduleCmd.Flags().StringVar(&scheduleConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	installScheduleCmd.Flags().IntVar(&scheduleInterval, "interval", 60, "Seconds between ticks")
+}
+
+// scheduleVariable is the Karabiner variable set while the schedule is active
+func scheduleVariable(name string) string {
+	return "schedule_" + name
+}
+
+// scheduleRule makes every manipulator of rule depend on the named schedule
+func scheduleRule(config *Config, rule Rule, name string) (Rule, error) {
+	schedule, ok := config.Schedules[name]
+	if !ok {
+		return Rule{}, fmt.Errorf("unknown schedule %q", name)
+	}
+	if _, err := scheduleActive(schedule, time.Now()); err != nil {
+		return Rule{}, fmt.Errorf("schedule %s: %w", name, err)
+	}
+
+	manipulators := make([]Manipulator, len(rule.Manipulators))
+	for i, m := range rule.Manipulators {
+		m.Conditions = append(append([]Condition{}, m.Conditions...), Condition{Type: "variable_if", Name: scheduleVariable(name), Value: 1})
+		manipulators[i] = m
+	}
+	rule.Manipulators = manipulators
+	return rule, nil
+}
+
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// scheduleActive reports whether now falls in the schedule's window. A window
+// past midnight belongs to the day it starts on.
+func scheduleActive(schedule ScheduleConfig, now time.Time) (bool, error) {
+	from, err := parseClock(schedule.From)
+	if err != nil {
+		return false, err
+	}
+	to, err := parseClock(schedule.To)
+	if err != nil {
+		return false, err
+	}
+
+	days := map[time.Weekday]bool{}
+	for _, day := range schedule.Days {
+		weekday, ok := scheduleDays[strings.ToLower(day)]
+		if !ok {
+			return false, fmt.Errorf("invalid day %q, expected mon, tue, ...", day)
+		}
+		days[weekday] = true
+	}
+	onDay := func(day time.Weekday) bool {
+		return len(days) == 0 || days[day]
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if from <= to {
+		return onDay(now.Weekday()) && minute >= from && minute < to, nil
+	}
+	yesterday := (now.Weekday() + 6) % 7
+	return (onDay(now.Weekday()) && minute >= from) || (onDay(yesterday) && minute < to), nil
+}
+
+func karabinerCLI(config *Config) string {
+	if path := config.Paths["karabiner_cli"]; path != "" {
+		return path
+	}
+	return karabinerCLIPath
+}
+
+func tickSchedules(config *Config, now time.Time) error {
+	if len(config.Schedules) == 0 {
+		return fmt.Errorf("no schedules configured")
+	}
+
+	names := make([]string, 0, len(config.Schedules))
+	for name := range config.Schedules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variables := map[string]int{}
+	for _, name := range names {
+		active, err := scheduleActive(config.Schedules[name], now)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", name, err)
+		}
+		variables[scheduleVariable(name)] = 0
+		if active {
+			variables[scheduleVariable(name)] = 1
+		}
+	}
+
+	data, err := json.Marshal(variables)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if out, err := exec.Command(karabinerCLI(config), "--set-variables", string(data)).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set variables: %s", strings.TrimSpace(string(out)+" "+err.Error()))
+	}
+	return nil
+}
+
+const scheduleAgentPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>schedule</string>
+		<string>tick</string>
+		<string>--config</string>
+		<string>%s</string>
+	</array>
+	<key>StartInterval</key>
+	<integer>%d</integer>
+	<key>RunAtLoad</key>
+	<true/>
+</dict>
+</plist>
+`
+
+func installScheduleAgent(config *Config, interval int) error {
+	if interval < 1 {
+		return fmt.Errorf("invalid interval %d", interval)
+	}
+	executable, err := karabingenExecutable()
+	if err != nil {
+		return err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	plistPath := filepath.Join(home, "Library", "LaunchAgents", scheduleAgentLabel+".plist")
+	plist := fmt.Sprintf(scheduleAgentPlist, scheduleAgentLabel, xmlEscape(executable), xmlEscape(config.path), interval)
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+	}
+	if err := os.WriteFile(plistPath, []byte(plist), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", plistPath, err)
+	}
+
+	// Reload in case an older version of the agent is running
+	exec.Command("launchctl", "unload", plistPath).Run()
+	if out, err := exec.Command("launchctl", "load", "-w", plistPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to load %s: %s", plistPath, strings.TrimSpace(string(out)+" "+err.Error()))
+	}
+
+	fmt.Printf("Installed %s, ticking every %ds\n", plistPath, interval)
+	return nil
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
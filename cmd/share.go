@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	shareConfigPath string
+	shareLayer      string
+	shareOutPath    string
+	addConfigPath   string
+	addYes          bool
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Export a layer as a shareable preset file",
+	Long: `Write one layer of the config to a self-contained preset file, along with the
+aliases its bindings use, for another user to install with "karabingen add":
+
+  karabingen share --layer o --out layer-o.yaml`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := localConfigPath(shareConfigPath)
+		if err != nil {
+			return err
+		}
+		return shareLayerPreset(configPath, shareLayer, shareOutPath)
+	},
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add <file|url>",
+	Short: "Install a shared preset file into the config",
+	Long: `Add the layers and aliases of a preset file written by "karabingen share" to the
+config. URLs may be pinned with a "#sha256=<hex>" fragment like remote configs,
+and plain http URLs must be. The shell commands, aliases and dynamic layer
+commands of the preset are printed for confirmation, which --yes skips. The
+config is validated before it's written and backed up to <config>.bak.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := localConfigPath(addConfigPath)
+		if err != nil {
+			return err
+		}
+		return addPreset(configPath, args[0], addYes)
+	},
+}
+
+func init() {
+	shareCmd.Flags().StringVar(&shareConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	shareCmd.Flags().StringVar(&shareLayer, "layer", "", "Key of the layer to export")
+	shareCmd.Flags().StringVar(&shareOutPath, "out", "", "Path of the preset file (default: stdout)")
+	shareCmd.MarkFlagRequired("layer")
+	addCmd.Flags().StringVar(&addConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	addCmd.Flags().BoolVarP(&addYes, "yes", "y", false, "Add the preset without asking for confirmation")
+}
+
+// localConfigPath returns the config path to edit, which can't be a URL
+func localConfigPath(configPath string) (string, error) {
+	configPath, err := resolveConfigPath(configPath)
+	if err != nil {
+		return "", err
+	}
+	if isRemoteConfig(configPath) {
+		return "", fmt.Errorf("cannot use remote config %s", configPath)
+	}
+	return configPath, nil
+}
+
+// readYAMLDocument parses a YAML file into its document node, keeping comments
+func readYAMLDocument(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse YAML: expected a mapping")
+	}
+	return &doc, nil
+}
+
+// mappingValue returns the value of key in a mapping node, creating it with
+// the given kind when create is set
+func mappingValue(mapping *yaml.Node, key string, kind yaml.Kind, create bool) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	if !create {
+		return nil
+	}
+	value := &yaml.Node{Kind: kind}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// encodeYAMLDocument renders a document node with the repo's 2 space indent
+func encodeYAMLDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// layerAliases returns the aliases the bindings of a layer run
+func layerAliases(layer LayerConfig) []string {
+	var aliases []string
+	var collect func(binding KeyBinding)
+	collect = func(binding KeyBinding) {
+		if binding.Type == "alias" {
+			aliases = append(aliases, binding.Val)
+		}
+		for _, action := range binding.Actions {
+			collect(action)
+		}
+	}
+	for _, sub := range layer.Sub {
+		binding := KeyBinding(sub)
+		if binding.Type == "" {
+			binding.Type = layer.Type
+		}
+		collect(binding)
+	}
+	return aliases
+}
+
+func shareLayerPreset(configPath, key, outPath string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	doc, err := readYAMLDocument(data)
+	if err != nil {
+		return err
+	}
+	root := doc.Content[0]
+
+	// The layer node is copied as written, comments included
+	var layerNode *yaml.Node
+	var layer LayerConfig
+	if layers := mappingValue(root, "keybindings", yaml.MappingNode, false); layers != nil {
+		if layers = mappingValue(layers, "layers", yaml.SequenceNode, false); layers != nil {
+			for _, node := range layers.Content {
+				var candidate LayerConfig
+				if err := node.Decode(&candidate); err != nil {
+					return fmt.Errorf("failed to parse layer: %w", err)
+				}
+				if candidate.Key == key {
+					layerNode, layer = node, candidate
+					break
+				}
+			}
+		}
+	}
+	if layerNode == nil {
+		return fmt.Errorf("no layer with key %q in keybindings.layers", key)
+	}
+
+	preset := &yaml.Node{Kind: yaml.MappingNode}
+	keybindings := mappingValue(preset, "keybindings", yaml.MappingNode, true)
+	mappingValue(keybindings, "layers", yaml.SequenceNode, true).Content = []*yaml.Node{layerNode}
+
+	if aliases := layerAliases(layer); len(aliases) > 0 {
+		configAliases := mappingValue(root, "aliases", yaml.MappingNode, false)
+		presetAliases := mappingValue(preset, "aliases", yaml.MappingNode, true)
+		for _, name := range aliases {
+			var command *yaml.Node
+			if configAliases != nil {
+				command = mappingValue(configAliases, name, yaml.ScalarNode, false)
+			}
+			if command == nil {
+				return fmt.Errorf("layer %s uses unknown alias %q", key, name)
+			}
+			if mappingValue(presetAliases, name, yaml.ScalarNode, false) == nil {
+				presetAliases.Content = append(presetAliases.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, command)
+			}
+		}
+	}
+
+	file := "<file>"
+	if outPath != "" {
+		file = filepath.Base(outPath)
+	}
+	preset.HeadComment = "karabingen layer preset, install with: karabingen add " + file
+	out, err := encodeYAMLDocument(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{preset}})
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+	if err := os.WriteFile(outPath, out, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	fmt.Printf("Layer %s written to: %s\n", key, outPath)
+	return nil
+}
+
+// layerCommands lists the commands a layer runs: its dynamic command and the
+// values of its shell bindings
+func layerCommands(layer LayerConfig) []string {
+	var commands []string
+	if layer.Type == "dynamic" {
+		commands = append(commands, fmt.Sprintf("layer %s dynamic command: %s", layer.Key, layer.Command))
+	}
+	layerType := layer.Type
+	if layerType == "dynamic" {
+		layerType = layer.SubType
+		if layerType == "" {
+			layerType = "shell"
+		}
+	}
+	var add func(subkey string, binding KeyBinding, inherited string)
+	add = func(subkey string, binding KeyBinding, inherited string) {
+		if binding.Type == "" {
+			binding.Type = inherited
+		}
+		for _, action := range binding.Actions {
+			add(subkey, action, binding.Type)
+		}
+		if binding.Type == "shell" && binding.Val != "" {
+			commands = append(commands, fmt.Sprintf("layer %s, %s: %s", layer.Key, subkey, binding.Val))
+		}
+	}
+	subkeys := make([]string, 0, len(layer.Sub))
+	for subkey := range layer.Sub {
+		subkeys = append(subkeys, subkey)
+	}
+	sort.Strings(subkeys)
+	for _, subkey := range subkeys {
+		add(subkey, KeyBinding(layer.Sub[subkey]), layerType)
+	}
+	return commands
+}
+
+func addPreset(configPath, source string, yes bool) error {
+	var presetData []byte
+	var err error
+	if isRemoteConfig(source) {
+		presetData, err = fetchRemoteConfig(source)
+	} else {
+		presetData, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read preset: %w", err)
+	}
+	preset, err := readYAMLDocument(presetData)
+	if err != nil {
+		return fmt.Errorf("preset: %w", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	doc, err := readYAMLDocument(data)
+	if err != nil {
+		return err
+	}
+	root := doc.Content[0]
+
+	// Layers are added, never replaced
+	added := 0
+	var commands []string
+	var presetLayers *yaml.Node
+	if keybindings := mappingValue(preset.Content[0], "keybindings", yaml.MappingNode, false); keybindings != nil {
+		presetLayers = mappingValue(keybindings, "layers", yaml.SequenceNode, false)
+	}
+	if presetLayers != nil {
+		layers := mappingValue(mappingValue(root, "keybindings", yaml.MappingNode, true), "layers", yaml.SequenceNode, true)
+		existing := map[string]bool{}
+		for _, node := range layers.Content {
+			var layer LayerConfig
+			if err := node.Decode(&layer); err == nil {
+				existing[layer.Key] = true
+			}
+		}
+		for _, node := range presetLayers.Content {
+			var layer LayerConfig
+			if err := node.Decode(&layer); err != nil {
+				return fmt.Errorf("preset: failed to parse layer: %w", err)
+			}
+			if existing[layer.Key] {
+				return fmt.Errorf("the config already has a layer on key %q", layer.Key)
+			}
+			layers.Content = append(layers.Content, node)
+			fmt.Printf("Adding layer %s\n", layer.Key)
+			commands = append(commands, layerCommands(layer)...)
+			added++
+		}
+	}
+
+	// Aliases are added unless the config has the same one
+	if presetAliases := mappingValue(preset.Content[0], "aliases", yaml.MappingNode, false); presetAliases != nil {
+		aliases := mappingValue(root, "aliases", yaml.MappingNode, true)
+		for i := 0; i+1 < len(presetAliases.Content); i += 2 {
+			name, command := presetAliases.Content[i].Value, presetAliases.Content[i+1]
+			if existing := mappingValue(aliases, name, yaml.ScalarNode, false); existing != nil {
+				if existing.Value != command.Value {
+					return fmt.Errorf("the config already has a different alias %q", name)
+				}
+				continue
+			}
+			aliases.Content = append(aliases.Content, presetAliases.Content[i], command)
+			fmt.Printf("Adding alias %s\n", name)
+			commands = append(commands, fmt.Sprintf("alias %s: %s", name, command.Value))
+		}
+	}
+	if added == 0 {
+		return fmt.Errorf("preset has no layers")
+	}
+
+	out, err := encodeYAMLDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	// Validate next to the config, so relative paths resolve the same way
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), ".karabingen-add-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	tmp.Close()
+	config, err := loadConfig(tmp.Name())
+	if err == nil {
+		_, err = createRules(config)
+	}
+	if err != nil {
+		return fmt.Errorf("config with the preset is invalid: %w", err)
+	}
+
+	if len(commands) > 0 {
+		fmt.Println("The preset runs these commands:")
+		for _, command := range commands {
+			fmt.Printf("  %s\n", command)
+		}
+	}
+	if !yes {
+		fmt.Print("Add the preset? (y/n): ")
+		confirm, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+			fmt.Println("Aborted, the config was not changed.")
+			return nil
+		}
+	}
+
+	if err := copyFile(configPath, configPath+".bak"); err != nil {
+		return fmt.Errorf("failed to back up config: %w", err)
+	}
+	if err := os.WriteFile(configPath, out, 0o644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	fmt.Printf("Config written to: %s, run \"karabingen generate\" to apply it\n", configPath)
+	return nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// shellQuote wraps a value in single quotes for use as one shell argument
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// safeShellWord matches words the shell takes literally
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellWord returns s as one shell argument, quoted only when needed, which
+// keeps generated commands readable
+func shellWord(s string) string {
+	if safeShellWord.MatchString(s) {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// shellJoin builds a command line running args, each one shell argument
+func shellJoin(args ...string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = shellWord(arg)
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuoteState returns the quote open at the end of a shell command line,
+// 0 for none, and whether its last character is an unquoted backslash
+func shellQuoteState(command string) (rune, bool) {
+	var quote rune
+	escaped := false
+	for _, r := range command {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+		case r == '\\':
+			escaped = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		}
+	}
+	return quote, escaped
+}
+
+// checkShellCommand finds the mistakes that make a generated command fail
+// whenever it runs: unterminated quotes and a missing executable given by its
+// absolute path
+func checkShellCommand(command string) error {
+	quote, escaped := shellQuoteState(command)
+	if quote != 0 {
+		return fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return fmt.Errorf("trailing backslash")
+	}
+
+	// The first word is the program, quoted or not
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return fmt.Errorf("empty command")
+	}
+	var path string
+	if command[0] == '\'' || command[0] == '"' {
+		path, _, _ = strings.Cut(command[1:], command[:1])
+	} else {
+		path = strings.Fields(command)[0]
+	}
+	if strings.HasPrefix(path, "/") {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%s does not exist", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// runShell runs a command line with /bin/sh and returns its output
+func runShell(t *testing.T, command string) string {
+	t.Helper()
+	out, err := exec.Command("/bin/sh", "-c", command).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %v: %s", command, err, out)
+	}
+	return string(out)
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", `''`},
+		{"plain", `'plain'`},
+		{"with space", `'with space'`},
+		{"it's", `'it'\''s'`},
+		{`"double"`, `'"double"'`},
+		{"$HOME `id` \\", "'$HOME `id` \\'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+		// The shell must get the value back unchanged
+		if got := runShell(t, "printf %s "+shellQuote(tt.in)); got != tt.in {
+			t.Errorf("shell read shellQuote(%q) as %q", tt.in, got)
+		}
+	}
+}
+
+func TestShellWord(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/usr/bin/tmux", "/usr/bin/tmux"},
+		{"user@host:22", "user@host:22"},
+		{"a,b=c+d%", "a,b=c+d%"},
+		{"", `''`},
+		{"~/.tmuxjumplist", `'~/.tmuxjumplist'`},
+		{"/Applications/Visual Studio Code.app", `'/Applications/Visual Studio Code.app'`},
+		{"https://example.com/?q=a&b", `'https://example.com/?q=a&b'`},
+		{"it's", `'it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := shellWord(tt.in); got != tt.want {
+			t.Errorf("shellWord(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"tmux", "switch"}, "tmux switch"},
+		{[]string{"/opt/my tools/karabingen", "tmux", "--jumplist", "~/jump list"}, `'/opt/my tools/karabingen' tmux --jumplist '~/jump list'`},
+		{[]string{"echo", "", "x"}, `echo '' x`},
+	}
+	for _, tt := range tests {
+		if got := shellJoin(tt.args...); got != tt.want {
+			t.Errorf("shellJoin(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	// Every argument reaches the program as one word
+	args := []string{"a b", "it's", `"q"`, "$x", ""}
+	got := runShell(t, shellJoin(append([]string{"printf", "[%s]"}, args...)...))
+	if want := "[a b][it's][\"q\"][$x][]"; got != want {
+		t.Errorf("shell split shellJoin(%q) into %s, want %s", args, got, want)
+	}
+}
+
+func TestCheckShellCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		wantErr string
+	}{
+		{"open -a Safari", ""},
+		{"echo 'balanced' \"too\"", ""},
+		{`echo 'it'\''s'`, ""},
+		{`echo "a \" quote"`, ""},
+		{"echo it\\'s", ""},
+		{"tmux new-window", ""},
+		{"relative/bin/tool --flag", ""},
+		{"/bin/sh -c true", ""},
+		{"'/bin/sh' -c true", ""},
+		{"echo 'unterminated", "unterminated ' quote"},
+		{`echo "unterminated`, `unterminated " quote`},
+		{`echo "it's`, `unterminated " quote`},
+		{`echo trailing \`, "trailing backslash"},
+		{"", "empty command"},
+		{"   ", "empty command"},
+		{"/no/such/bin --flag", "/no/such/bin does not exist"},
+		{"'/no/such dir/bin' --flag", "/no/such dir/bin does not exist"},
+	}
+	for _, tt := range tests {
+		err := checkShellCommand(tt.command)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("checkShellCommand(%q) = %v, want no error", tt.command, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("checkShellCommand(%q) = %v, want %s", tt.command, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShellQuoteSecrets(t *testing.T) {
+	tests := []struct {
+		in, want, wantErr string
+	}{
+		{"plain", `'plain'`, ""},
+		{"", `''`, ""},
+		{"Bearer {env:API_TOKEN}", `'Bearer '"${API_TOKEN}"`, ""},
+		{"{env:A}{env:B}", `"${A}""${B}"`, ""},
+		{"{keychain:my token}", `"$(security find-generic-password -w -s 'my token')"`, ""},
+		{"it's {env:X}", `'it'\''s '"${X}"`, ""},
+		{"{env:}", "", "empty env name"},
+		{"{env:NOT-VALID}", "", "invalid environment variable"},
+		{"{keychain:}", "", "empty keychain name"},
+	}
+	for _, tt := range tests {
+		got, err := shellQuoteSecrets(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("shellQuoteSecrets(%q) error = %v, want %s", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("shellQuoteSecrets(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+
+	// The environment is read when the command runs
+	quoted, err := shellQuoteSecrets("token: {env:KARABINGEN_TEST_TOKEN}!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KARABINGEN_TEST_TOKEN", "s3cr3t 'x'")
+	if got := runShell(t, "printf %s "+quoted); got != "token: s3cr3t 'x'!" {
+		t.Errorf("shell read %s as %q", quoted, got)
+	}
+}
+
+func TestExpandSecrets(t *testing.T) {
+	tests := []struct {
+		in, want, wantErr string
+	}{
+		{"echo hi", "echo hi", ""},
+		{"curl -H 'x' -H Authorization:{env:TOKEN}", `curl -H 'x' -H Authorization:"${TOKEN}"`, ""},
+		{"echo {keychain:gh}", `echo "$(security find-generic-password -w -s 'gh')"`, ""},
+		{"echo {env:1BAD}", "", "invalid environment variable"},
+		{"echo {keychain:}", "", "empty keychain name"},
+		{`echo "token {env:TOKEN}"`, `echo "token "${TOKEN}""`, ""},
+		{"echo it\\'s {env:TOKEN}", "echo it\\'s \"${TOKEN}\"", ""},
+		{"echo '{env:TOKEN}'", "", "inside single quotes"},
+		{`curl -H 'Authorization: {keychain:gh}'`, "", "inside single quotes"},
+	}
+	for _, tt := range tests {
+		got, err := expandSecrets(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expandSecrets(%q) error = %v, want %s", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("expandSecrets(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Safari", `"Safari"`},
+		{"", `""`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+		{`\"`, `"\\\""`},
+		{"it's", `"it's"`},
+	}
+	for _, tt := range tests {
+		if got := appleScriptString(tt.in); got != tt.want {
+			t.Errorf("appleScriptString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestOsascriptCommand(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{nil, "osascript"},
+		{[]string{`tell application "Safari" to activate`}, `osascript -e 'tell application "Safari" to activate'`},
+		{[]string{"if true then", "beep", "end if"}, `osascript -e 'if true then' -e 'beep' -e 'end if'`},
+		{[]string{`display dialog "it's"`}, `osascript -e 'display dialog "it'\''s"'`},
+	}
+	for _, tt := range tests {
+		if got := osascriptCommand(tt.lines...); got != tt.want {
+			t.Errorf("osascriptCommand(%q) = %s, want %s", tt.lines, got, tt.want)
+		}
+		if err := checkShellCommand(tt.want); err != nil {
+			t.Errorf("checkShellCommand(%s) = %v", tt.want, err)
+		}
+	}
+}
+
+func TestCreateCharTo(t *testing.T) {
+	for _, char := range []string{"é", "☃", "ñ"} {
+		to, err := createCharTo(char)
+		if err != nil {
+			t.Fatalf("createCharTo(%q): %v", char, err)
+		}
+		want := osascriptCommand(`tell application "System Events" to keystroke ` + appleScriptString(char))
+		if to.ShellCommand != want {
+			t.Errorf("createCharTo(%q) = %s, want %s", char, to.ShellCommand, want)
+		}
+	}
+	// Quotes and backslashes are typed by their keys
+	for _, char := range []string{"'", `"`, `\`} {
+		to, err := createCharTo(char)
+		if err != nil || to.KeyCode == "" {
+			t.Errorf("createCharTo(%q) = %+v, %v, want a key event", char, to, err)
+		}
+	}
+	if _, err := createCharTo(""); err == nil {
+		t.Error("createCharTo(\"\") succeeded, want an error")
+	}
+}
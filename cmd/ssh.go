@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// createSSHLayer builds a shell layer opening a terminal window with an ssh
+// session to a host. Without hosts in the config, every host of the ssh config
+// gets its first free letter or digit.
+func createSSHLayer(config *Config, sshConfig SSHLayerConfig) (LayerConfig, error) {
+	terminal, err := lookupTerminal(config.Terminals, sshConfig.Terminal)
+	if err != nil {
+		return LayerConfig{}, err
+	}
+
+	hosts := sshConfig.Hosts
+	if len(hosts) == 0 {
+		path, err := expandPath(sshConfig.ConfigPath)
+		if err != nil {
+			return LayerConfig{}, err
+		}
+		names, err := readSSHHosts(path)
+		if err != nil {
+			return LayerConfig{}, err
+		}
+		hosts = assignSSHKeys(names, sshConfig.Key)
+	}
+
+	sub := make(map[string]LayerBinding, len(hosts))
+	for key, host := range hosts {
+		line := "ssh " + shellQuote(host)
+		if sshConfig.Tmux != "" {
+			// -A attaches to the session, creating it on the first connection
+			line = fmt.Sprintf("ssh -t %s %s", shellQuote(host), shellQuote("tmux new-session -A -s "+shellQuote(sshConfig.Tmux)))
+		}
+		sub[key] = LayerBinding{Val: expandTerminalTemplate(terminal.OpenNewWindow, line), Description: "ssh " + host}
+	}
+
+	return LayerConfig{
+		Key:         sshConfig.Key,
+		Type:        "shell",
+		Sub:         sub,
+		Mode:        sshConfig.Mode,
+		Description: "SSH hosts",
+	}, nil
+}
+
+// readSSHHosts returns the host aliases of an ssh config in file order,
+// skipping patterns like "*.example.com" that name no single host
+func readSSHHosts(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	defer file.Close()
+
+	var hosts []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(strings.ReplaceAll(scanner.Text(), "=", " "))
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "host") {
+			continue
+		}
+		for _, host := range fields[1:] {
+			if strings.HasPrefix(host, "#") {
+				break
+			}
+			if strings.ContainsAny(host, "*?!") || seen[host] {
+				continue
+			}
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	return hosts, nil
+}
+
+// assignSSHKeys gives every host the first letter or digit of its name not
+// taken yet, other than the layer key; hosts left without one are skipped
+func assignSSHKeys(hosts []string, layerKey string) map[string]string {
+	assigned := map[string]string{}
+	taken := map[string]bool{layerKey: true}
+	for _, host := range hosts {
+		for _, r := range strings.ToLower(host) {
+			key := string(r)
+			if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) || taken[key] {
+				continue
+			}
+			taken[key] = true
+			assigned[key] = host
+			break
+		}
+	}
+	return assigned
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var statsTmuxCmd = &cobra.Command{
+	Use:   "stats [jumplist_file]",
+	Short: "Show how often each jumplist key is used",
+	Long: `Show how often each key of the tmux jump list was used, most used first, and
+suggest the keys never used, whose slots could go to other sessions.
+Jumps are only counted with tmux_jump.track_usage enabled, in
+~/.cache/karabingen/tmux_usage.json.
+If no jumplist file is specified, defaults to ~/.tmuxjumplist.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jumplistFile := "~/.tmuxjumplist"
+		if len(args) >= 1 {
+			jumplistFile = args[0]
+		}
+		if strings.HasPrefix(jumplistFile, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+			jumplistFile = filepath.Join(home, jumplistFile[2:])
+		}
+		return printJumpStats(jumplistFile)
+	},
+}
+
+// jumpUsage counts the jumps with one key to its session
+type jumpUsage struct {
+	Session  string    `json:"session"`
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+func jumpUsagePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "karabingen", "tmux_usage.json"), nil
+}
+
+// readJumpUsage returns the jump counts by key, empty if nothing was counted yet
+func readJumpUsage() (map[string]jumpUsage, error) {
+	path, err := jumpUsagePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]jumpUsage{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tmux usage: %w", err)
+	}
+	usage := map[string]jumpUsage{}
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("failed to parse tmux usage %s: %w", path, err)
+	}
+	return usage, nil
+}
+
+// recordJump counts a jump with the key; a key bound to another session since
+// starts counting again
+func recordJump(key, session string, now time.Time) error {
+	usage, err := readJumpUsage()
+	if err != nil {
+		return err
+	}
+	entry := usage[key]
+	if entry.Session != session {
+		entry = jumpUsage{Session: session}
+	}
+	entry.Count++
+	entry.LastUsed = now
+	usage[key] = entry
+
+	path, err := jumpUsagePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write tmux usage: %w", err)
+	}
+	return nil
+}
+
+// jumpStat is a jumplist key with its usage, as printed by tmux stats --json
+type jumpStat struct {
+	Key      string     `json:"key"`
+	Session  string     `json:"session"`
+	Count    int        `json:"count"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+func printJumpStats(jumplistFile string) error {
+	entries, err := readJumplistEntries(jumplistFile)
+	if err != nil {
+		return err
+	}
+	usage, err := readJumpUsage()
+	if err != nil {
+		return err
+	}
+
+	stats := make([]jumpStat, 0, len(entries))
+	var unused []string
+	for _, entry := range entries {
+		stat := jumpStat{Key: entry.Key, Session: entry.Session}
+		if counted, ok := usage[entry.Key]; ok && counted.Session == entry.Session {
+			stat.Count = counted.Count
+			stat.LastUsed = &counted.LastUsed
+		}
+		if stat.Count == 0 {
+			unused = append(unused, entry.Key)
+		}
+		stats = append(stats, stat)
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Count > stats[j].Count })
+
+	if jsonOutput {
+		if unused == nil {
+			unused = []string{}
+		}
+		return printJSON(struct {
+			Keys   []jumpStat `json:"keys"`
+			Unused []string   `json:"unused"`
+		}{stats, unused})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, stat := range stats {
+		lastUsed := "never"
+		if stat.LastUsed != nil {
+			lastUsed = stat.LastUsed.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", stat.Key, stat.Session, stat.Count, lastUsed)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(usage) == 0 {
+		fmt.Println("\nNo jumps counted yet, enable tmux_jump.track_usage and regenerate.")
+	} else if len(unused) > 0 {
+		fmt.Printf("\nNever used: %s; remove them from the jumplist to free the keys for other sessions.\n", strings.Join(unused, ", "))
+	}
+	return nil
+}
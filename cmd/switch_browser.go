@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fzfPath           string
+	fzfOptions        []string
+	browserConfigPath string
+	browserName       string
+	restoreMinimized  bool
+	browserSort       string
+)
+
+var switchBrowserCmd = &cobra.Command{
+	Use:   "switch",
+	Short: "Switch between browser tabs using fzf",
+	Long: `Opens an interactive fzf menu to search and switch between all open browser tabs.
+Tabs in minimized windows are marked [min], pinned tabs [pin] (for Safari detected
+when they appear in every window). Requires fzf to be installed (brew install fzf).
+
+Tabs are listed by frecency: the tabs switched to often and recently come first,
+as recorded in ~/.cache/karabingen/<browser>_tabs.json. --sort=window keeps the
+window and tab order.
+
+Keys in the picker:
+  enter   switch to the tab
+  ctrl-y  copy the tab's URL to the clipboard
+  ctrl-o  open the tab's URL in the default browser
+  ctrl-w  close the tab`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backend, err := lookupBrowserBackend(browserName)
+		if err != nil {
+			return err
+		}
+		picker, err := loadFzfPicker(browserConfigPath, fzfPath, fzfOptions)
+		if err != nil {
+			return err
+		}
+		if browserSort != "frecency" && browserSort != "window" {
+			return fmt.Errorf("unsupported sort: %s (supported: frecency, window)", browserSort)
+		}
+		return switchBrowserTab(browserName, backend, picker, restoreMinimized, browserSort == "frecency")
+	},
+}
+
+func init() {
+	switchBrowserCmd.Flags().StringVar(&browserName, "browser", "safari", "Browser to switch tabs of")
+	switchBrowserCmd.Flags().StringVar(&fzfPath, "fzf", "", "Path to fzf binary (default: paths.fzf from the config or fzf in $PATH)")
+	switchBrowserCmd.Flags().StringArrayVar(&fzfOptions, "fzf-opt", nil, "Extra fzf option, e.g. --fzf-opt=--height=40% (repeatable, added after fzf_options from the config)")
+	switchBrowserCmd.Flags().StringVar(&browserConfigPath, "config", "", "Path to YAML config file with fzf settings (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	switchBrowserCmd.Flags().BoolVar(&restoreMinimized, "restore", true, "Restore a minimized window when one of its tabs is selected")
+	switchBrowserCmd.Flags().StringVar(&browserSort, "sort", "frecency", "Order of the tabs: frecency or window")
+}
+
+func switchBrowserTab(browser string, backend browserBackend, picker fzfPicker, restoreMinimized, byFrecency bool) error {
+	tabs, err := backend.ListTabs()
+	if err != nil {
+		return err
+	}
+	if byFrecency {
+		history, err := readTabHistory(browser)
+		if err != nil {
+			return err
+		}
+		rankBrowserTabs(tabs, history, time.Now())
+	}
+
+	// Pipe to fzf for selection
+	key, selection := picker.pickWithKeys(formatBrowserTabs(tabs), []string{"ctrl-y", "ctrl-o", "ctrl-w"},
+		"--delimiter="+browserDelimiter, "--with-nth=3,4,5",
+		"--header=enter: switch, ctrl-y: copy url, ctrl-o: open in browser, ctrl-w: close")
+	if selection == "" {
+		return nil
+	}
+
+	tab, err := parseBrowserSelection(selection)
+	if err != nil {
+		return err
+	}
+
+	switch key {
+	case "ctrl-y":
+		cmd := exec.Command("pbcopy")
+		cmd.Stdin = strings.NewReader(tab.url)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to copy url: %w", err)
+		}
+		return nil
+	case "ctrl-o":
+		if err := exec.Command("open", tab.url).Run(); err != nil {
+			return fmt.Errorf("failed to open url: %w", err)
+		}
+		return nil
+	case "ctrl-w":
+		return backend.CloseTab(tab)
+	}
+
+	// Switch to selected tab and raise its window, restoring it if minimized
+	if err := backend.ActivateTab(tab, restoreMinimized); err != nil {
+		return err
+	}
+	if err := recordTabSwitch(browser, tab.url, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
@@ -13,21 +13,32 @@ import (
 )
 
 var (
-	tmuxPath     string
-	jumplistPath string
-	terminal     string
+	tmuxPath       string
+	jumplistPath   string
+	terminal       string
+	tmuxSocket     string
+	tmuxConfigPath string
+	trackUsage     bool
 )
 
 var switchTmuxCmd = &cobra.Command{
 	Use:   "switch <key>",
 	Short: "Switch to a tmux session based on jumplist",
 	Long: `Switch to a tmux session by reading the jumplist file and jumping to the session
-corresponding to the provided key (0-9, a-z).`,
+corresponding to the provided key (0-9, a-z).
+
+Entries may name the tmux server as a fourth field, key:session:directory:socket,
+overriding --socket. A socket containing "/" is a socket path (tmux -S), anything
+else a socket name (tmux -L).`,
 	Args:         cobra.ExactArgs(1),
 	SilenceUsage: true, // Don't show usage on errors
 	RunE: func(cmd *cobra.Command, args []string) error {
 		key := args[0]
-		if err := switchTmuxSession(key, tmuxPath, jumplistPath, terminal); err != nil {
+		terminalConfig, err := loadTerminal(tmuxConfigPath, terminal)
+		if err == nil {
+			err = switchTmuxSession(key, tmuxPath, jumplistPath, terminalConfig, tmuxSocket, trackUsage)
+		}
+		if err != nil {
 			// Log error to a file for debugging instead of stdout
 			logError(err)
 			return nil // Return nil to avoid showing usage and exit code 1
@@ -37,16 +48,18 @@ corresponding to the provided key (0-9, a-z).`,
 }
 
 func init() {
-	switchTmuxCmd.Flags().StringVar(&tmuxPath, "tmux", "/opt/homebrew/bin/tmux", "Path to tmux binary")
+	switchTmuxCmd.Flags().StringVar(&tmuxPath, "tmux", "", "Path to tmux binary (default: tmux in $PATH or a Homebrew/MacPorts prefix)")
 	switchTmuxCmd.Flags().StringVar(&jumplistPath, "jumplist", "~/.tmuxjumplist", "Path to jumplist file")
-	switchTmuxCmd.Flags().StringVar(&terminal, "terminal", "alacritty", "Terminal to use (alacritty, iterm2, terminal, ghostty)")
+	switchTmuxCmd.Flags().StringVar(&terminal, "terminal", "alacritty", "Terminal to use (alacritty, iterm2, terminal, ghostty or one from the terminals config)")
+	switchTmuxCmd.Flags().StringVar(&tmuxSocket, "socket", "", "tmux server socket name or path (default: the default server)")
+	switchTmuxCmd.Flags().StringVar(&tmuxConfigPath, "config", "", "Config file to read custom terminals from")
+	switchTmuxCmd.Flags().BoolVar(&trackUsage, "track-usage", false, "Count the jump for \"karabingen tmux stats\"")
 	switchTmuxCmd.MarkFlagRequired("jumplist")
 }
 
-func switchTmuxSession(key, tmuxPath, jumplistPath, terminal string) error {
-	// Special case: 0 opens the jumplist file for editing
-	if key == "0" {
-		return editJumplist(jumplistPath, terminal)
+func switchTmuxSession(key, tmuxPath, jumplistPath string, terminal TerminalConfig, socket string, trackUsage bool) error {
+	if tmuxPath == "" {
+		tmuxPath = findExecutable("tmux")
 	}
 
 	// Expand home directory in jumplist path
@@ -60,12 +73,12 @@ func switchTmuxSession(key, tmuxPath, jumplistPath, terminal string) error {
 
 	// Read jumplist file
 	sessions, err := readJumplist(jumplistPath)
-	if err != nil {
+	if err != nil && key != "0" {
 		return fmt.Errorf("failed to read jumplist %s: %w", jumplistPath, err)
 	}
 
 	// Find session for the given key
-	// Format: key:session_name or key:session_name:directory
+	// Format: key:session_name, key:session_name:directory or key:session_name:directory:socket
 	var sessionName, directory string
 	for _, line := range sessions {
 		parts := strings.Split(line, ":")
@@ -80,10 +93,21 @@ func switchTmuxSession(key, tmuxPath, jumplistPath, terminal string) error {
 					directory = filepath.Join(home, directory[2:])
 				}
 			}
+			if len(parts) >= 4 && strings.TrimSpace(parts[3]) != "" {
+				socket = strings.TrimSpace(parts[3])
+			}
 			break
 		}
 	}
 
+	// Every tmux invocation targets the entry's server
+	tmux := append([]string{tmuxPath}, tmuxSocketArgs(socket)...)
+
+	// Special case: 0 opens the jumplist file for editing, unless it is bound to a session
+	if sessionName == "" && key == "0" {
+		return editJumplist(tmux, jumplistPath, terminal)
+	}
+
 	if sessionName == "" {
 		return fmt.Errorf("no session found for key '%s' in jumplist %s", key, jumplistPath)
 	}
@@ -93,17 +117,29 @@ func switchTmuxSession(key, tmuxPath, jumplistPath, terminal string) error {
 		directory, _ = os.UserHomeDir()
 	}
 
+	if err := attachTmuxSession(tmux, terminal, sessionName, directory); err != nil {
+		return err
+	}
+	if trackUsage {
+		return recordJump(key, sessionName, time.Now())
+	}
+	return nil
+}
+
+// attachTmuxSession brings the session up in the terminal, creating it in
+// directory if needed: it switches the most recent client, types the attach
+// command into an open terminal window or opens a new one
+func attachTmuxSession(tmux []string, terminal TerminalConfig, sessionName, directory string) error {
 	// Ensure tmux session exists (create if needed)
-	ensureTmuxSession(tmuxPath, sessionName, directory)
+	ensureTmuxSession(tmux, sessionName, directory)
 
-	// Get terminal app name from terminal type
-	terminalApp := getTerminalAppName(terminal)
+	terminalApp := terminal.App
 
 	// Try to switch existing tmux client first
-	mostRecentClient := getMostRecentTmuxClient(tmuxPath)
+	mostRecentClient := getMostRecentTmuxClient(tmux)
 	if mostRecentClient != "" {
 		// Switch existing client and focus terminal
-		exec.Command(tmuxPath, "switch-client", "-c", mostRecentClient, "-t", sessionName).Run()
+		exec.Command(tmux[0], append(tmux[1:], "switch-client", "-c", mostRecentClient, "-t", sessionName)...).Run()
 		exec.Command("open", "-a", terminalApp).Run()
 		return nil
 	}
@@ -111,13 +147,13 @@ func switchTmuxSession(key, tmuxPath, jumplistPath, terminal string) error {
 	// No tmux clients found. Check if terminal has windows
 	windowCount := countTerminalWindows(terminalApp)
 	if windowCount > 0 {
-		// Type attach command into existing terminal window
-		typeIntoTerminal(terminalApp, sessionName, tmuxPath)
+		// Run attach command in existing terminal window
+		terminal.runCommand(tmuxShellCommand(tmux, "attach", "-t", sessionName))
 		return nil
 	}
 
 	// Last resort: create new window
-	return createNewWindow(terminal, tmuxPath, sessionName)
+	return terminal.openNewWindow(tmuxShellCommand(tmux, "attach", "-t", sessionName))
 }
 
 func readJumplist(path string) ([]string, error) {
@@ -139,7 +175,7 @@ func readJumplist(path string) ([]string, error) {
 	return lines, scanner.Err()
 }
 
-func editJumplist(jumplistPath, terminal string) error {
+func editJumplist(tmux []string, jumplistPath string, terminal TerminalConfig) error {
 	// Expand home directory
 	if strings.HasPrefix(jumplistPath, "~/") {
 		home, err := os.UserHomeDir()
@@ -157,59 +193,47 @@ func editJumplist(jumplistPath, terminal string) error {
 	// Check if we're inside tmux
 	if os.Getenv("TMUX") != "" {
 		// Inside tmux: open in new window
-		cmd := exec.Command("/opt/homebrew/bin/tmux", "new-window", fmt.Sprintf("%s %s", editor, jumplistPath))
+		cmd := exec.Command(tmux[0], append(tmux[1:], "new-window", editor+" "+shellQuote(jumplistPath))...)
 		return cmd.Run()
 	}
 
-	// Outside tmux: open in terminal
-	switch terminal {
-	case "iterm2":
-		script := fmt.Sprintf(`tell application "iTerm" to create window with default profile command "%s %s"`, editor, jumplistPath)
-		cmd := exec.Command("osascript", "-e", script)
-		return cmd.Run()
-	case "terminal":
-		script := fmt.Sprintf(`tell application "Terminal" to do script "%s %s"`, editor, jumplistPath)
-		cmd := exec.Command("osascript", "-e", script)
-		return cmd.Run()
-	case "alacritty":
-		cmd := exec.Command("/Applications/Alacritty.app/Contents/MacOS/alacritty", "-e", editor, jumplistPath)
-		return cmd.Run()
-	case "ghostty":
-		cmd := exec.Command("/Applications/Ghostty.app/Contents/MacOS/ghostty", "-e", editor, jumplistPath)
-		return cmd.Run()
-	default:
-		return fmt.Errorf("unsupported terminal: %s", terminal)
+	// Outside tmux: open in terminal, $EDITOR may contain arguments
+	return terminal.openNewWindow(editor + " " + shellQuote(jumplistPath))
+}
+
+// tmuxSocketArgs selects a tmux server by socket path (-S) or socket name (-L)
+func tmuxSocketArgs(socket string) []string {
+	if socket == "" {
+		return nil
+	}
+	if strings.Contains(socket, "/") {
+		return []string{"-S", socket}
 	}
+	return []string{"-L", socket}
 }
 
-func getTerminalAppName(terminal string) string {
-	switch terminal {
-	case "alacritty":
-		return "Alacritty"
-	case "iterm2":
-		return "iTerm"
-	case "terminal":
-		return "Terminal"
-	case "ghostty":
-		return "Ghostty"
-	default:
-		return terminal
+// tmuxShellCommand joins a tmux command line into a shell command
+func tmuxShellCommand(tmux []string, args ...string) string {
+	words := make([]string, 0, len(tmux)+len(args))
+	for _, word := range append(tmux, args...) {
+		words = append(words, shellQuote(word))
 	}
+	return strings.Join(words, " ")
 }
 
-func ensureTmuxSession(tmuxPath, sessionName, directory string) {
+func ensureTmuxSession(tmux []string, sessionName, directory string) {
 	// Check if session exists
-	cmd := exec.Command(tmuxPath, "has-session", "-t", sessionName)
+	cmd := exec.Command(tmux[0], append(tmux[1:], "has-session", "-t", sessionName)...)
 	if err := cmd.Run(); err != nil {
 		// Session doesn't exist, create it
-		exec.Command(tmuxPath, "new-session", "-d", "-s", sessionName, "-c", directory).Run()
+		exec.Command(tmux[0], append(tmux[1:], "new-session", "-d", "-s", sessionName, "-c", directory)...).Run()
 	}
 }
 
-func getMostRecentTmuxClient(tmuxPath string) string {
+func getMostRecentTmuxClient(tmux []string) string {
 	// Get most recently used tmux client
 	cmd := exec.Command("sh", "-c",
-		fmt.Sprintf(`"%s" list-clients -F '#{client_tty} #{client_activity}' 2>/dev/null | sort -k2nr | awk 'NR==1{print $1}'`, tmuxPath))
+		fmt.Sprintf(`%s list-clients -F '#{client_tty} #{client_activity}' 2>/dev/null | sort -k2nr | awk 'NR==1{print $1}'`, tmuxShellCommand(tmux)))
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -220,10 +244,10 @@ func getMostRecentTmuxClient(tmuxPath string) string {
 func countTerminalWindows(terminalApp string) int {
 	script := fmt.Sprintf(`
 tell application "System Events"
-  set isRunning to (exists process "%s")
+  set isRunning to (exists process %s)
   if isRunning then
     try
-      set winCount to count windows of process "%s"
+      set winCount to count windows of process %s
     on error
       set winCount to 0
     end try
@@ -231,7 +255,7 @@ tell application "System Events"
     set winCount to 0
   end if
 end tell
-return winCount`, terminalApp, terminalApp)
+return winCount`, appleScriptString(terminalApp), appleScriptString(terminalApp))
 
 	cmd := exec.Command("osascript", "-e", script)
 	output, err := cmd.Output()
@@ -244,44 +268,6 @@ return winCount`, terminalApp, terminalApp)
 	return count
 }
 
-func typeIntoTerminal(terminalApp, sessionName, tmuxPath string) error {
-	script := fmt.Sprintf(`
-tell application "%s" to activate
-delay 0.005
-tell application "System Events"
-  keystroke "%s attach -t %s"
-  key code 36
-end tell`, terminalApp, tmuxPath, sessionName)
-
-	cmd := exec.Command("osascript", "-e", script)
-	return cmd.Run()
-}
-
-func createNewWindow(terminal, tmuxPath, sessionName string) error {
-	switch terminal {
-	case "iterm2":
-		script := fmt.Sprintf(`tell application "iTerm" to create window with default profile command "%s attach -t %s"`, tmuxPath, sessionName)
-		cmd := exec.Command("osascript", "-e", script)
-		return cmd.Run()
-	case "terminal":
-		script := fmt.Sprintf(`
-tell application "Terminal"
-    do script "%s attach -t %s"
-    activate
-end tell`, tmuxPath, sessionName)
-		cmd := exec.Command("osascript", "-e", script)
-		return cmd.Run()
-	case "alacritty":
-		cmd := exec.Command("/Applications/Alacritty.app/Contents/MacOS/alacritty", "-e", tmuxPath, "attach", "-t", sessionName)
-		return cmd.Start() // Use Start instead of Run to not block
-	case "ghostty":
-		cmd := exec.Command("/Applications/Ghostty.app/Contents/MacOS/ghostty", "-e", tmuxPath, "attach", "-t", sessionName)
-		return cmd.Start()
-	default:
-		return fmt.Errorf("unsupported terminal: %s", terminal)
-	}
-}
-
 func logError(err error) {
 	// Log errors to a debug file for troubleshooting
 	home, homeErr := os.UserHomeDir()
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	switcherConfigPath string
+	switcherBrowser    string
+	switcherFzfPath    string
+	switcherFzfOptions []string
+)
+
+var switcherCmd = &cobra.Command{
+	Use:   "switcher",
+	Short: "Switch to any app window, browser tab or tmux session using fzf",
+	Long: `Opens one fzf menu listing app windows [win], tmux sessions [tmux] and browser
+tabs [tab], and switches to the selection: a single "go to anything" key, e.g.
+
+  karabingen popup -- karabingen switcher
+
+tmux sessions are listed from the tmux_jump server and opened in its terminal.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backend, err := lookupBrowserBackend(switcherBrowser)
+		if err != nil {
+			return err
+		}
+		tmuxConfig, terminal, err := loadSwitcherTmuxConfig(switcherConfigPath)
+		if err != nil {
+			return err
+		}
+		picker, err := loadFzfPicker(switcherConfigPath, switcherFzfPath, switcherFzfOptions)
+		if err != nil {
+			return err
+		}
+		return switchToAnything(backend, tmuxConfig, terminal, picker)
+	},
+}
+
+func init() {
+	switcherCmd.Flags().StringVar(&switcherConfigPath, "config", "", "Path to YAML config file (default: $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml)")
+	switcherCmd.Flags().StringVar(&switcherBrowser, "browser", "safari", "Browser to list tabs of")
+	switcherCmd.Flags().StringVar(&switcherFzfPath, "fzf", "", "Path to fzf binary (default: paths.fzf from the config or fzf in $PATH)")
+	switcherCmd.Flags().StringArrayVar(&switcherFzfOptions, "fzf-opt", nil, "Extra fzf option (repeatable, added after fzf_options from the config)")
+}
+
+// loadSwitcherTmuxConfig reads the tmux_jump settings and its terminal,
+// falling back to the defaults without a config
+func loadSwitcherTmuxConfig(configPath string) (TmuxJumpConfig, TerminalConfig, error) {
+	config, err := loadDefaultConfig(configPath)
+	if errors.Is(err, errNoConfig) {
+		return TmuxJumpConfig{TmuxPath: findExecutable("tmux"), Terminal: "alacritty"}, builtinTerminals["alacritty"], nil
+	}
+	if err != nil {
+		return TmuxJumpConfig{}, TerminalConfig{}, err
+	}
+	terminal, err := lookupTerminal(config.Terminals, config.TmuxJump.Terminal)
+	if err != nil {
+		return TmuxJumpConfig{}, TerminalConfig{}, err
+	}
+	tmuxConfig := config.TmuxJump
+	if tmuxConfig.TmuxPath = config.tmuxPath(); tmuxConfig.TmuxPath == "" {
+		tmuxConfig.TmuxPath = findExecutable("tmux")
+	}
+	return tmuxConfig, terminal, nil
+}
+
+// AppleScript to list the windows of all foreground apps as app name, window index and title
+const listAppWindowsScript = `
+tell application "System Events"
+	set output to ""
+	set sep to ASCII character 31
+	repeat with p in (processes whose background only is false)
+		try
+			set appName to name of p
+			repeat with i from 1 to count windows of p
+				set output to output & appName & sep & i & sep & (name of window i of p) & linefeed
+			end repeat
+		end try
+	end repeat
+	return output
+end tell
+`
+
+// switcherItems renders the fzf input: kind, two kind-specific fields and the label.
+// Windows come first, then tmux sessions and tabs, and fzf keeps that order for ties.
+func switcherItems(backend browserBackend, tmux []string) string {
+	var b strings.Builder
+	item := func(kind, first, second, label string) {
+		fmt.Fprintf(&b, "%s%s%s%s%s%s%s\n", kind, browserDelimiter, first, browserDelimiter, second, browserDelimiter, label)
+	}
+
+	if output, err := exec.Command("osascript", "-e", listAppWindowsScript).Output(); err == nil {
+		for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+			parts := strings.Split(line, browserDelimiter)
+			if len(parts) < 3 || parts[2] == "" {
+				continue
+			}
+			item("win", parts[0], parts[1], fmt.Sprintf("[win]  %s — %s", parts[0], parts[2]))
+		}
+	}
+
+	if output, err := exec.Command(tmux[0], append(tmux[1:], "list-sessions", "-F", "#{session_name}")...).Output(); err == nil {
+		for _, session := range strings.Fields(string(output)) {
+			item("tmux", session, "", "[tmux] "+session)
+		}
+	}
+
+	if tabs, err := backend.ListTabs(); err == nil {
+		for _, tab := range tabs {
+			item("tab", tab.windowID, strconv.Itoa(tab.index), fmt.Sprintf("[tab]  %s — %s", tab.name, tab.url))
+		}
+	}
+
+	return b.String()
+}
+
+func switchToAnything(backend browserBackend, tmuxConfig TmuxJumpConfig, terminal TerminalConfig, picker fzfPicker) error {
+	tmux := append([]string{tmuxConfig.TmuxPath}, tmuxSocketArgs(tmuxConfig.Socket)...)
+
+	selection := picker.pick(switcherItems(backend, tmux), "--delimiter="+browserDelimiter, "--with-nth=4", "--tiebreak=index")
+	if selection == "" {
+		return nil
+	}
+
+	parts := strings.SplitN(selection, browserDelimiter, 4)
+	if len(parts) < 4 {
+		return fmt.Errorf("invalid selection format")
+	}
+
+	switch parts[0] {
+	case "win":
+		script := fmt.Sprintf(`tell application "System Events" to tell process %s
+	perform action "AXRaise" of window %s
+	set frontmost to true
+end tell`, appleScriptString(parts[1]), parts[2])
+		if err := exec.Command("osascript", "-e", script).Run(); err != nil {
+			return fmt.Errorf("failed to focus window: %w", err)
+		}
+		return nil
+	case "tmux":
+		return attachTmuxSession(tmux, terminal, parts[1], "")
+	case "tab":
+		index, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return fmt.Errorf("invalid selection format")
+		}
+		return backend.ActivateTab(browserTab{windowID: parts[1], index: index}, true)
+	}
+	return fmt.Errorf("invalid selection format")
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import "fmt"
+
+// symbolKeys maps a printable symbol to the US layout key that produces it
+var symbolKeys = map[string]To{
+	"!":  {KeyCode: "1", Modifiers: []string{"shift"}},
+	"@":  {KeyCode: "2", Modifiers: []string{"shift"}},
+	"#":  {KeyCode: "3", Modifiers: []string{"shift"}},
+	"$":  {KeyCode: "4", Modifiers: []string{"shift"}},
+	"%":  {KeyCode: "5", Modifiers: []string{"shift"}},
+	"^":  {KeyCode: "6", Modifiers: []string{"shift"}},
+	"&":  {KeyCode: "7", Modifiers: []string{"shift"}},
+	"*":  {KeyCode: "8", Modifiers: []string{"shift"}},
+	"(":  {KeyCode: "9", Modifiers: []string{"shift"}},
+	")":  {KeyCode: "0", Modifiers: []string{"shift"}},
+	"-":  {KeyCode: "hyphen"},
+	"_":  {KeyCode: "hyphen", Modifiers: []string{"shift"}},
+	"=":  {KeyCode: "equal_sign"},
+	"+":  {KeyCode: "equal_sign", Modifiers: []string{"shift"}},
+	"[":  {KeyCode: "open_bracket"},
+	"{":  {KeyCode: "open_bracket", Modifiers: []string{"shift"}},
+	"]":  {KeyCode: "close_bracket"},
+	"}":  {KeyCode: "close_bracket", Modifiers: []string{"shift"}},
+	"\\": {KeyCode: "backslash"},
+	"|":  {KeyCode: "backslash", Modifiers: []string{"shift"}},
+	";":  {KeyCode: "semicolon"},
+	":":  {KeyCode: "semicolon", Modifiers: []string{"shift"}},
+	"'":  {KeyCode: "quote"},
+	"\"": {KeyCode: "quote", Modifiers: []string{"shift"}},
+	",":  {KeyCode: "comma"},
+	"<":  {KeyCode: "comma", Modifiers: []string{"shift"}},
+	".":  {KeyCode: "period"},
+	">":  {KeyCode: "period", Modifiers: []string{"shift"}},
+	"/":  {KeyCode: "slash"},
+	"?":  {KeyCode: "slash", Modifiers: []string{"shift"}},
+	"`":  {KeyCode: "grave_accent_and_tilde"},
+	"~":  {KeyCode: "grave_accent_and_tilde", Modifiers: []string{"shift"}},
+}
+
+// optionChars maps special characters to their US layout Option combination
+var optionChars = map[string]To{
+	"–": {KeyCode: "hyphen", Modifiers: []string{"option"}},
+	"—": {KeyCode: "hyphen", Modifiers: []string{"option", "shift"}},
+	"°": {KeyCode: "8", Modifiers: []string{"option", "shift"}},
+	"•": {KeyCode: "8", Modifiers: []string{"option"}},
+	"…": {KeyCode: "semicolon", Modifiers: []string{"option"}},
+	"≠": {KeyCode: "equal_sign", Modifiers: []string{"option"}},
+	"±": {KeyCode: "equal_sign", Modifiers: []string{"option", "shift"}},
+	"≤": {KeyCode: "comma", Modifiers: []string{"option"}},
+	"≥": {KeyCode: "period", Modifiers: []string{"option"}},
+	"÷": {KeyCode: "slash", Modifiers: []string{"option"}},
+	"©": {KeyCode: "g", Modifiers: []string{"option"}},
+	"™": {KeyCode: "2", Modifiers: []string{"option"}},
+	"€": {KeyCode: "2", Modifiers: []string{"option", "shift"}},
+	"£": {KeyCode: "3", Modifiers: []string{"option"}},
+	"«": {KeyCode: "backslash", Modifiers: []string{"option"}},
+	"»": {KeyCode: "backslash", Modifiers: []string{"option", "shift"}},
+}
+
+// defaultCharsLayout holds frequently used characters for the chars layer
+var defaultCharsLayout = map[string]string{
+	"d": "—",
+	"n": "–",
+	"o": "°",
+	"b": "•",
+	"e": "…",
+	"x": "×",
+	"p": "±",
+	"q": "≠",
+	"a": "→",
+}
+
+// createCharTo types a single character, using a key combination when one is
+// known and falling back to System Events keystroke otherwise
+func createCharTo(char string) (To, error) {
+	if to, ok := symbolKeys[char]; ok {
+		return to, nil
+	}
+	if to, ok := optionChars[char]; ok {
+		return to, nil
+	}
+	if char == "" {
+		return To{}, fmt.Errorf("cannot type an empty character")
+	}
+	return To{
+		ShellCommand: osascriptCommand(`tell application "System Events" to keystroke ` + appleScriptString(char)),
+	}, nil
+}
+
+// defaultSymbolsLayout puts brackets and operators on the home row
+var defaultSymbolsLayout = map[string]string{
+	"a":         "(",
+	"s":         ")",
+	"d":         "[",
+	"f":         "]",
+	"g":         "{",
+	"h":         "}",
+	"j":         "<",
+	"k":         ">",
+	"l":         "=",
+	"semicolon": "+",
+	"q":         "!",
+	"w":         "@",
+	"e":         "#",
+	"r":         "$",
+	"t":         "%",
+	"y":         "^",
+	"u":         "&",
+	"i":         "*",
+	"o":         "-",
+	"p":         "_",
+	"z":         "|",
+	"x":         "\\",
+	"c":         "/",
+	"v":         "?",
+	"b":         "~",
+	"n":         "`",
+	"m":         "\"",
+	"comma":     "'",
+	"period":    ":",
+}
+
+// mergeLayout applies user overrides to a default layout, an empty value removes the key
+func mergeLayout(defaults, overrides map[string]string) map[string]LayerBinding {
+	layout := make(map[string]string, len(defaults))
+	for key, val := range defaults {
+		layout[key] = val
+	}
+	for key, val := range overrides {
+		if val == "" {
+			delete(layout, key)
+			continue
+		}
+		layout[key] = val
+	}
+
+	bindings := make(map[string]LayerBinding, len(layout))
+	for key, val := range layout {
+		bindings[key] = LayerBinding{Val: val}
+	}
+	return bindings
+}
+
+// createCharsLayer builds a "char" layer from the default characters and user overrides
+func createCharsLayer(charsConfig CharsLayerConfig) LayerConfig {
+	return LayerConfig{
+		Key:  charsConfig.Key,
+		Type: "char",
+		Sub:  mergeLayout(defaultCharsLayout, charsConfig.Chars),
+	}
+}
+
+// createSymbolsLayer builds a "symbol" layer from the default layout and user overrides
+func createSymbolsLayer(symbolsConfig SymbolsLayerConfig) LayerConfig {
+	return LayerConfig{
+		Key:       symbolsConfig.Key,
+		Type:      "symbol",
+		Sub:       mergeLayout(defaultSymbolsLayout, symbolsConfig.Layout),
+		Mode:      symbolsConfig.Mode,
+		TimeoutMs: symbolsConfig.TimeoutMs,
+	}
+}
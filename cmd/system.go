@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// macOSMajorVersion evaluates to the running macOS major version in a shell command
+const macOSMajorVersion = `"$(sw_vers -productVersion | cut -d. -f1)"`
+
+// dndShortcut is the Shortcuts.app shortcut toggling Focus on macOS 12+
+const dndShortcut = "Toggle Do Not Disturb"
+
+// dndCommand toggles Focus through Shortcuts on macOS 12+, notifying when the
+// shortcut is missing. Big Sur ignores the doNotDisturb default, there an
+// option-click on the menu bar clock toggles it; older versions read the default.
+var dndCommand = `if [ ` + macOSMajorVersion + ` -ge 12 ]; then ` +
+	`if shortcuts list | grep -qxF ` + shellQuote(dndShortcut) + `; then ` +
+	`shortcuts run ` + shellQuote(dndShortcut) + `; ` +
+	`else ` +
+	osascriptCommand(`display notification `+appleScriptString(`Create a Shortcuts shortcut named "`+dndShortcut+`" with the Set Focus action`)+
+		` with title "karabingen"`) + `; ` +
+	`fi; ` +
+	`elif [ ` + macOSMajorVersion + ` -eq 11 ]; then ` +
+	osascriptCommand(
+		`tell application "System Events"`,
+		`tell process "ControlCenter"`,
+		`key down option`,
+		`click menu bar item "Clock" of menu bar 1`,
+		`key up option`,
+		`end tell`,
+		`end tell`,
+	) + `; ` +
+	`else ` +
+	`if [ "$(defaults -currentHost read com.apple.notificationcenterui doNotDisturb 2>/dev/null)" = 1 ]; then ` +
+	`defaults -currentHost write com.apple.notificationcenterui doNotDisturb -boolean false; ` +
+	`else ` +
+	`defaults -currentHost write com.apple.notificationcenterui doNotDisturb -boolean true; ` +
+	`fi; ` +
+	`killall NotificationCenter; ` +
+	`fi`
+
+// systemActions are the named presets available as "sys:<name>" binding values
+var systemActions = map[string]To{
+	"sleep": {
+		SoftwareFunction: &SoftwareFunction{
+			IOKitPowerManagementSleepSystem: &IOKitPowerManagementSleepSystem{DelayMilliseconds: 500},
+		},
+	},
+	"display-sleep": {
+		ShellCommand: "pmset displaysleepnow",
+	},
+	"lock": {
+		KeyCode:   "q",
+		Modifiers: []string{"control", "command"},
+	},
+	"caffeinate": {
+		ShellCommand: "if pgrep -x caffeinate >/dev/null; then pkill -x caffeinate; else nohup caffeinate -dims >/dev/null 2>&1 & fi",
+	},
+	"emoji": {
+		KeyCode:   "spacebar",
+		Modifiers: []string{"control", "command"},
+	},
+	"dnd": {
+		ShellCommand: dndCommand,
+	},
+	"dark-mode": {
+		ShellCommand: osascriptCommand(`tell application "System Events" to tell appearance preferences to set dark mode to not dark mode`),
+	},
+}
+
+func createSystemActionTo(name string) (To, error) {
+	to, ok := systemActions[name]
+	if !ok {
+		names := make([]string, 0, len(systemActions))
+		for n := range systemActions {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return To{}, fmt.Errorf("unknown system action sys:%s (available: %s)", name, strings.Join(names, ", "))
+	}
+	return to, nil
+}
+
+// settingsPane holds the pane identifiers of System Settings (macOS 13+) and
+// of the older System Preferences
+type settingsPane struct {
+	settings    string
+	preferences string
+}
+
+var settingsPanes = map[string]settingsPane{
+	"general":       {"com.apple.systempreferences.GeneralSettings", "com.apple.preference.general"},
+	"accessibility": {"com.apple.Accessibility-Settings.extension", "com.apple.preference.universalaccess"},
+	"battery":       {"com.apple.Battery-Settings.extension", "com.apple.preference.battery"},
+	"bluetooth":     {"com.apple.BluetoothSettings", "com.apple.preferences.Bluetooth"},
+	"desktop":       {"com.apple.Desktop-Settings.extension", "com.apple.preference.dock"},
+	"displays":      {"com.apple.Displays-Settings.extension", "com.apple.preference.displays"},
+	"keyboard":      {"com.apple.Keyboard-Settings.extension", "com.apple.preference.keyboard"},
+	"mouse":         {"com.apple.Mouse-Settings.extension", "com.apple.preference.mouse"},
+	"network":       {"com.apple.Network-Settings.extension", "com.apple.preference.network"},
+	"notifications": {"com.apple.Notifications-Settings.extension", "com.apple.preference.notifications"},
+	"printers":      {"com.apple.Print-Scanner-Settings.extension", "com.apple.preference.printfax"},
+	"privacy":       {"com.apple.settings.PrivacySecurity.extension", "com.apple.preference.security"},
+	"sound":         {"com.apple.Sound-Settings.extension", "com.apple.preference.sound"},
+	"trackpad":      {"com.apple.Trackpad-Settings.extension", "com.apple.preference.trackpad"},
+	"users":         {"com.apple.Users-Groups-Settings.extension", "com.apple.preferences.users"},
+	"wifi":          {"com.apple.wifi-settings-extension", "com.apple.preference.network"},
+}
+
+// createSettingsCommand opens a System Settings pane by friendly name, picking
+// the pane identifier that matches the running macOS version
+func createSettingsCommand(name string) (string, error) {
+	pane, ok := settingsPanes[name]
+	if !ok {
+		names := make([]string, 0, len(settingsPanes))
+		for n := range settingsPanes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown settings pane %q (available: %s)", name, strings.Join(names, ", "))
+	}
+
+	return fmt.Sprintf(`if [ %s -ge 13 ]; then open "x-apple.systempreferences:%s"; else open "x-apple.systempreferences:%s"; fi`,
+		macOSMajorVersion, pane.settings, pane.preferences), nil
+}
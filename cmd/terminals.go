@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// builtinTerminals are the terminals karabingen knows without a terminals
+// section in the config
+var builtinTerminals = map[string]TerminalConfig{
+	"alacritty": {
+		App:           "Alacritty",
+		OpenNewWindow: "open -n -a Alacritty --args -e {args}",
+		Popup: "open -n -a Alacritty --args --title karabingen-popup" +
+			" -o window.dimensions.columns={columns} -o window.dimensions.lines={lines}" +
+			" -o window.position.x={x} -o window.position.y={y} -e {args}",
+	},
+	"ghostty": {
+		App:           "Ghostty",
+		OpenNewWindow: "open -n -a Ghostty --args -e {args}",
+		Popup: "open -n -a Ghostty --args --title=karabingen-popup --window-width={columns} --window-height={lines}" +
+			" --window-position-x={x} --window-position-y={y} --quit-after-last-window-closed=true -e {args}",
+	},
+	"iterm2": {
+		App:           "iTerm",
+		OpenNewWindow: `osascript -e 'tell application "iTerm" to create window with default profile command {applescript}'`,
+		Popup: `osascript -e 'tell application "iTerm"' -e 'set popup to (create window with default profile command {applescript})'` +
+			` -e 'set bounds of popup to {{x}, {y}, {right}, {bottom}}' -e 'activate' -e 'end tell'`,
+	},
+	"terminal": {
+		App:           "Terminal",
+		OpenNewWindow: `osascript -e 'tell application "Terminal"' -e 'do script {applescript}' -e 'activate' -e 'end tell'`,
+		Popup: `osascript -e 'tell application "Terminal"' -e 'do script {applescript} & "; exit"'` +
+			` -e 'set bounds of front window to {{x}, {y}, {right}, {bottom}}' -e 'activate' -e 'end tell'`,
+	},
+}
+
+// lookupTerminal returns the launch templates of a terminal. Configured
+// terminals add to the built-in ones, or override single templates of them.
+func lookupTerminal(terminals map[string]TerminalConfig, name string) (TerminalConfig, error) {
+	terminal, builtin := builtinTerminals[name]
+	configured, ok := terminals[name]
+	if !builtin && !ok {
+		names := make([]string, 0, len(builtinTerminals)+len(terminals))
+		for name := range builtinTerminals {
+			names = append(names, name)
+		}
+		for name := range terminals {
+			if _, ok := builtinTerminals[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return TerminalConfig{}, fmt.Errorf("unsupported terminal: %s (supported: %s)", name, strings.Join(names, ", "))
+	}
+
+	if configured.App != "" {
+		terminal.App = configured.App
+	}
+	if configured.OpenNewWindow != "" {
+		terminal.OpenNewWindow = configured.OpenNewWindow
+	}
+	if configured.RunCommand != "" {
+		terminal.RunCommand = configured.RunCommand
+	}
+	if configured.Popup != "" {
+		terminal.Popup = configured.Popup
+	}
+	if terminal.App == "" {
+		terminal.App = name
+	}
+	if terminal.OpenNewWindow == "" {
+		return TerminalConfig{}, fmt.Errorf("terminal %s has no open_new_window template", name)
+	}
+	return terminal, nil
+}
+
+// expandTerminalTemplate fills a template with a shell command line:
+// {command} is the line as one shell word, {args} the line as is, e.g. after
+// -e, and {applescript} the line as an AppleScript string inside single quotes.
+// placeholders are more name, value pairs, e.g. the popup geometry.
+func expandTerminalTemplate(template, line string, placeholders ...string) string {
+	applescript := strings.ReplaceAll(appleScriptString(line), "'", `'\''`)
+	pairs := append([]string{"{command}", shellQuote(line), "{args}", line, "{applescript}", applescript}, placeholders...)
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// openNewWindow opens a new terminal window running the command line
+func (t TerminalConfig) openNewWindow(line string) error {
+	return exec.Command("/bin/sh", "-c", expandTerminalTemplate(t.OpenNewWindow, line)).Run()
+}
+
+// runCommand runs the command line in the front window of the terminal, by
+// typing it unless a run_command template is configured
+func (t TerminalConfig) runCommand(line string) error {
+	if t.RunCommand != "" {
+		return exec.Command("/bin/sh", "-c", expandTerminalTemplate(t.RunCommand, line)).Run()
+	}
+	script := fmt.Sprintf(`
+tell application %s to activate
+delay 0.005
+tell application "System Events"
+  keystroke %s
+  key code 36
+end tell`, appleScriptString(t.App), appleScriptString(line))
+	return exec.Command("osascript", "-e", script).Run()
+}
+
+// loadTerminal resolves a terminal for the runtime commands, reading the
+// terminals section of the config when given one
+func loadTerminal(configPath, name string) (TerminalConfig, error) {
+	if configPath == "" {
+		return lookupTerminal(nil, name)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return TerminalConfig{}, err
+	}
+	return lookupTerminal(config.Terminals, name)
+}
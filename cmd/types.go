@@ -1,21 +1,61 @@
 package cmd
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 type Parameters struct {
-	BasicToIfAloneTimeoutMilliseconds int `json:"basic.to_if_alone_timeout_milliseconds,omitempty"`
+	BasicToIfAloneTimeoutMilliseconds      int `json:"basic.to_if_alone_timeout_milliseconds,omitempty"`
+	BasicToDelayedActionDelayMilliseconds  int `json:"basic.to_delayed_action_delay_milliseconds,omitempty"`
+	BasicToIfHeldDownThresholdMilliseconds int `json:"basic.to_if_held_down_threshold_milliseconds,omitempty"`
+	BasicSimultaneousThresholdMilliseconds int `json:"basic.simultaneous_threshold_milliseconds,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type ProfileParameters struct {
+	DelayMillisecondsBeforeOpenDevice int                        `json:"delay_milliseconds_before_open_device,omitempty"`
+	Extra                             map[string]json.RawMessage `json:"-"` // fields unknown to karabingen, kept as is
 }
 
 type Profile struct {
-	Name                 string                `json:"name"`
-	Selected             bool                  `json:"selected"`
-	VirtualHIDKeyboard   *VirtualHIDKeyboard   `json:"virtual_hid_keyboard,omitempty"`
-	SimpleModifications  []SimpleModification  `json:"simple_modifications,omitempty"`
-	ComplexModifications *ComplexModifications `json:"complex_modifications,omitempty"`
-	Devices              []interface{}         `json:"devices,omitempty"`
-	Parameters           *Parameters           `json:"parameters,omitempty"`
+	Name                 string                     `json:"name"`
+	Selected             bool                       `json:"selected"`
+	VirtualHIDKeyboard   *VirtualHIDKeyboard        `json:"virtual_hid_keyboard,omitempty"`
+	SimpleModifications  []SimpleModification       `json:"simple_modifications,omitempty"`
+	ComplexModifications *ComplexModifications      `json:"complex_modifications,omitempty"`
+	Devices              []interface{}              `json:"devices,omitempty"`
+	Parameters           *ProfileParameters         `json:"parameters,omitempty"`
+	Extra                map[string]json.RawMessage `json:"-"` // fields unknown to karabingen, kept as is
+}
+
+type Device struct {
+	Identifiers         DeviceIdentifiers    `json:"identifiers"`
+	FnFunctionKeys      []DeviceModification `json:"fn_function_keys,omitempty"`
+	SimpleModifications []DeviceModification `json:"simple_modifications,omitempty"`
+}
+
+type DeviceIdentifiers struct {
+	IsKeyboard bool `json:"is_keyboard"`
+	ProductID  int  `json:"product_id"`
+	VendorID   int  `json:"vendor_id"`
+}
+
+type DeviceModification struct {
+	From DeviceKey   `json:"from"`
+	To   []DeviceKey `json:"to"`
+}
+
+type DeviceKey struct {
+	KeyCode         string `json:"key_code,omitempty" yaml:"key_code"`
+	ConsumerKeyCode string `json:"consumer_key_code,omitempty" yaml:"consumer_key_code"`
+	PointingButton  string `json:"pointing_button,omitempty" yaml:"pointing_button"`
 }
 
 type VirtualHIDKeyboard struct {
-	KeyboardTypeV2 string `json:"keyboard_type_v2,omitempty"`
+	KeyboardTypeV2 string                     `json:"keyboard_type_v2,omitempty"`
+	Extra          map[string]json.RawMessage `json:"-"` // fields unknown to karabingen, kept as is
 }
 
 type SimpleModification struct {
@@ -24,7 +64,9 @@ type SimpleModification struct {
 }
 
 type ComplexModifications struct {
-	Rules []Rule `json:"rules"`
+	Parameters *Parameters                `json:"parameters,omitempty"`
+	Rules      []Rule                     `json:"rules"`
+	Extra      map[string]json.RawMessage `json:"-"` // fields unknown to karabingen, kept as is
 }
 
 type Rule struct {
@@ -33,28 +75,49 @@ type Rule struct {
 }
 
 type Manipulator struct {
-	Type         string      `json:"type"`
-	Description  string      `json:"description,omitempty"`
-	From         From        `json:"from"`
-	To           []To        `json:"to,omitempty"`
-	ToIfAlone    []To        `json:"to_if_alone,omitempty"`
-	ToAfterKeyUp []To        `json:"to_after_key_up,omitempty"`
-	Conditions   []Condition `json:"conditions,omitempty"`
-	Parameters   *Parameters `json:"parameters,omitempty"`
+	Type            string           `json:"type"`
+	Description     string           `json:"description,omitempty"`
+	From            From             `json:"from"`
+	To              []To             `json:"to,omitempty"`
+	ToIfAlone       []To             `json:"to_if_alone,omitempty"`
+	ToIfHeldDown    []To             `json:"to_if_held_down,omitempty"`
+	ToAfterKeyUp    []To             `json:"to_after_key_up,omitempty"`
+	ToDelayedAction *ToDelayedAction `json:"to_delayed_action,omitempty"`
+	Conditions      []Condition      `json:"conditions,omitempty"`
+	Parameters      *Parameters      `json:"parameters,omitempty"`
+}
+
+type ToDelayedAction struct {
+	ToIfInvoked  []To `json:"to_if_invoked,omitempty"`
+	ToIfCanceled []To `json:"to_if_canceled,omitempty"`
 }
 
 type From struct {
-	KeyCode        string     `json:"key_code,omitempty"`
-	PointingButton string     `json:"pointing_button,omitempty"`
-	Modifiers      *Modifiers `json:"modifiers,omitempty"`
+	KeyCode             string               `json:"key_code,omitempty"`
+	Any                 string               `json:"any,omitempty"`
+	PointingButton      string               `json:"pointing_button,omitempty"`
+	Simultaneous        []SimultaneousKey    `json:"simultaneous,omitempty"`
+	SimultaneousOptions *SimultaneousOptions `json:"simultaneous_options,omitempty"`
+	Modifiers           *Modifiers           `json:"modifiers,omitempty"`
+}
+
+type SimultaneousKey struct {
+	KeyCode string `json:"key_code"`
+}
+
+type SimultaneousOptions struct {
+	KeyDownOrder string `json:"key_down_order,omitempty"`
 }
 
 type To struct {
-	KeyCode          string            `json:"key_code,omitempty"`
-	Modifiers        []string          `json:"modifiers,omitempty"`
-	ShellCommand     string            `json:"shell_command,omitempty"`
-	SetVariable      *SetVariable      `json:"set_variable,omitempty"`
-	SoftwareFunction *SoftwareFunction `json:"software_function,omitempty"`
+	KeyCode                string                  `json:"key_code,omitempty"`
+	ConsumerKeyCode        string                  `json:"consumer_key_code,omitempty"`
+	Modifiers              []string                `json:"modifiers,omitempty"`
+	ShellCommand           string                  `json:"shell_command,omitempty"`
+	SetVariable            *SetVariable            `json:"set_variable,omitempty"`
+	SoftwareFunction       *SoftwareFunction       `json:"software_function,omitempty"`
+	SetNotificationMessage *SetNotificationMessage `json:"set_notification_message,omitempty"`
+	Repeat                 *bool                   `json:"repeat,omitempty"`
 }
 
 type KeyCode struct {
@@ -71,8 +134,25 @@ type SetVariable struct {
 	Value int    `json:"value"`
 }
 
+type SetNotificationMessage struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
 type SoftwareFunction struct {
-	OpenApplication *OpenApplication `json:"open_application,omitempty"`
+	OpenApplication                 *OpenApplication                 `json:"open_application,omitempty"`
+	SetMouseCursorPosition          *SetMouseCursorPosition          `json:"set_mouse_cursor_position,omitempty"`
+	IOKitPowerManagementSleepSystem *IOKitPowerManagementSleepSystem `json:"iokit_power_management_sleep_system,omitempty"`
+}
+
+type IOKitPowerManagementSleepSystem struct {
+	DelayMilliseconds int `json:"delay_milliseconds,omitempty"`
+}
+
+type SetMouseCursorPosition struct {
+	X      string `json:"x"`
+	Y      string `json:"y"`
+	Screen int    `json:"screen"`
 }
 
 type OpenApplication struct {
@@ -80,17 +160,43 @@ type OpenApplication struct {
 }
 
 type Condition struct {
-	Type              string   `json:"type"`
-	Name              string   `json:"name,omitempty"`
-	Value             int      `json:"value"`
-	BundleIdentifiers []string `json:"bundle_identifiers,omitempty"`
+	Type              string             `json:"type"`
+	Name              string             `json:"name,omitempty"`
+	Value             int                `json:"value"`
+	BundleIdentifiers []string           `json:"bundle_identifiers,omitempty"`
+	Identifiers       []DeviceIdentifier `json:"identifiers,omitempty"` // devices of device_if and device_unless
+}
+
+// MarshalJSON writes value only for variable conditions, the other types don't
+// take one
+func (c Condition) MarshalJSON() ([]byte, error) {
+	type plain Condition
+	if strings.HasPrefix(c.Type, "variable_") {
+		return json.Marshal(plain(c))
+	}
+	return json.Marshal(struct {
+		plain
+		Value *int `json:"value,omitempty"`
+	}{plain: plain(c)})
+}
+
+// DeviceIdentifier matches devices in device conditions, unset fields match
+// every device
+type DeviceIdentifier struct {
+	VendorID         int   `json:"vendor_id,omitempty"`
+	ProductID        int   `json:"product_id,omitempty"`
+	IsKeyboard       *bool `json:"is_keyboard,omitempty"`
+	IsPointingDevice *bool `json:"is_pointing_device,omitempty"`
+	IsTouchBar       *bool `json:"is_touch_bar,omitempty"`
 }
 
 type KarabinerConfig struct {
-	Global   Global    `json:"global"`
-	Profiles []Profile `json:"profiles"`
+	Global   Global                     `json:"global"`
+	Profiles []Profile                  `json:"profiles"`
+	Extra    map[string]json.RawMessage `json:"-"` // fields unknown to karabingen, kept as is
 }
 
 type Global struct {
-	ShowProfileNameInMenuBar bool `json:"show_profile_name_in_menu_bar,omitempty"`
+	ShowProfileNameInMenuBar bool                       `json:"show_profile_name_in_menu_bar,omitempty"`
+	Extra                    map[string]json.RawMessage `json:"-"` // fields unknown to karabingen, kept as is
 }
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	undoOutputPath string
+	undoYes        bool
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Restore karabiner.json from the most recent backup",
+	Long: `Replace karabiner.json with the most recent backup_*.json next to it, after
+previewing the changes. The replaced karabiner.json is kept for redo, and
+undoing again goes one more backup back. Generating again drops what redo
+would restore.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filePath, err := resolveOutputPath(undoOutputPath)
+		if err != nil {
+			return err
+		}
+		return undoKarabinerConfig(filePath, undoYes)
+	},
+}
+
+var redoCmd = &cobra.Command{
+	Use:   "redo",
+	Short: "Restore the karabiner.json replaced by the last undo",
+	Long: `Put back the karabiner.json the last undo replaced, after previewing the
+changes. The replaced karabiner.json is backed up, so undo can go back again.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filePath, err := resolveOutputPath(undoOutputPath)
+		if err != nil {
+			return err
+		}
+		return redoKarabinerConfig(filePath, undoYes)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{undoCmd, redoCmd} {
+		cmd.Flags().StringVarP(&undoOutputPath, "output", "o", "", "Path to karabiner.json file")
+		cmd.Flags().BoolVarP(&undoYes, "yes", "y", false, "Restore without asking for confirmation")
+	}
+}
+
+func undoKarabinerConfig(filePath string, yes bool) error {
+	backupPath, err := latestBackup(filepath.Dir(filePath))
+	if err != nil {
+		return fmt.Errorf("%w, nothing to undo", err)
+	}
+	return restoreKarabinerConfig(filePath, backupPath, "redo", yes)
+}
+
+func redoKarabinerConfig(filePath string, yes bool) error {
+	redoPath, err := latestSnapshot(filepath.Dir(filePath), "redo")
+	if err != nil {
+		return fmt.Errorf("%w, nothing to redo", err)
+	}
+	return restoreKarabinerConfig(filePath, redoPath, "backup", yes)
+}
+
+// restoreKarabinerConfig moves snapshotPath over filePath, keeping the replaced
+// file as a <keepAs>_*.json snapshot
+func restoreKarabinerConfig(filePath, snapshotPath, keepAs string, yes bool) error {
+	snapshotConfig, err := readKarabinerConfig(snapshotPath)
+	if err != nil {
+		return err
+	}
+	var currentConfig KarabinerConfig
+	_, statErr := os.Stat(filePath)
+	if statErr == nil {
+		if currentConfig, err = readKarabinerConfig(filePath); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Restoring %s from %s\n", filePath, snapshotPath)
+	if printConfigDiff(os.Stdout, currentConfig, snapshotConfig) == 0 {
+		fmt.Println("No rule changes.")
+	}
+	if !yes {
+		fmt.Print("Restore? (y/n): ")
+		confirm, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+			fmt.Println("Aborted, karabiner.json was not changed.")
+			return nil
+		}
+	}
+
+	if statErr == nil {
+		keepPath := newSnapshotPath(filepath.Dir(filePath), keepAs)
+		if err := copyFile(filePath, keepPath); err != nil {
+			return fmt.Errorf("failed to keep %s: %w", filePath, err)
+		}
+	}
+	if err := os.Rename(snapshotPath, filePath); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", snapshotPath, err)
+	}
+	fmt.Printf("Configuration restored: %s\n", filePath)
+	return nil
+}
+
+// newSnapshotPath returns a new <prefix>_<timestamp>.json path in dir. The
+// timestamp goes down to the nanosecond, so snapshots taken within the same
+// second don't overwrite each other and the names still sort chronologically.
+func newSnapshotPath(dir, prefix string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.json", prefix, time.Now().Format("20060102_150405.000000000")))
+}
+
+// latestSnapshot returns the most recent <prefix>_*.json in dir; the
+// timestamped names sort chronologically
+func latestSnapshot(dir, prefix string) (string, error) {
+	snapshots, err := filepath.Glob(filepath.Join(dir, prefix+"_*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(snapshots) == 0 {
+		return "", fmt.Errorf("no %s files found in %s", prefix, dir)
+	}
+	sort.Strings(snapshots)
+	return snapshots[len(snapshots)-1], nil
+}
+
+// clearRedo removes the redo_*.json files of dir, which a new generation
+// makes stale
+func clearRedo(dir string) {
+	snapshots, _ := filepath.Glob(filepath.Join(dir, "redo_*.json"))
+	for _, snapshot := range snapshots {
+		os.Remove(snapshot)
+	}
+}
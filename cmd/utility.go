@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+)
+
+// screenshotDir resolves the screenshot location configured in macOS
+const screenshotDir = `"$(defaults read com.apple.screencapture location 2>/dev/null || echo ~/Desktop)"`
+
+// clipboardManagers holds the clipboard history trigger of each supported app
+var clipboardManagers = map[string]LayerBinding{
+	"raycast": {Type: "shell", Val: `open -g "raycast://extensions/raycast/clipboard-history/clipboard-history"`},
+	"maccy":   {Type: "key", Val: "c", Modifiers: ModifierList{"shift", "command"}},
+	"paste":   {Type: "key", Val: "v", Modifiers: ModifierList{"shift", "command"}},
+}
+
+// createUtilityLayer builds a layer with clipboard history, screenshots and screen recording
+func createUtilityLayer(utilityConfig UtilityLayerConfig) (LayerConfig, error) {
+	sub := map[string]LayerBinding{
+		"3":       {Type: "key", Val: "3", Modifiers: ModifierList{"shift", "command"}},
+		"4":       {Type: "key", Val: "4", Modifiers: ModifierList{"shift", "command"}},
+		"w":       {Type: "shell", Val: fmt.Sprintf(`screencapture -iWo %s/"Screenshot $(date +%%Y-%%m-%%d-%%H%%M%%S).png"`, screenshotDir)},
+		"r":       {Type: "key", Val: "5", Modifiers: ModifierList{"shift", "command"}},
+		"shift+r": {Type: "key", Val: "escape", Modifiers: ModifierList{"control", "command"}},
+	}
+
+	if utilityConfig.Clipboard != "" {
+		clipboard, ok := clipboardManagers[utilityConfig.Clipboard]
+		if !ok {
+			return LayerConfig{}, fmt.Errorf("unsupported clipboard manager: %s (supported: raycast, maccy, paste)", utilityConfig.Clipboard)
+		}
+		sub["v"] = clipboard
+	}
+
+	return LayerConfig{
+		Key: utilityConfig.Key,
+		Sub: sub,
+	}, nil
+}
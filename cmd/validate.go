@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [config_path|config_url]",
+	Short: "Check a config against Karabiner's key codes and modifiers",
+	Long: `Check a config before generating from it: key codes against the Karabiner key
+code list (built in, or cached by "karabingen keys update"), modifier names, layer keys
+used twice or clashing with option bindings, and bindings shadowed by others
+(see lint). Exits non-zero when a problem is found.
+Without an argument, uses $KARABINGEN_CONFIG or ~/.config/karabingen/config.yaml`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := ""
+		if len(args) > 0 {
+			configPath = args[0]
+		}
+		config, err := loadDefaultConfig(configPath)
+		if err != nil {
+			return err
+		}
+
+		definitions, err := cachedKeyDefinitions()
+		if err != nil {
+			return err
+		}
+		problems, err := checkProfiles(config, func(config *Config) ([]string, error) {
+			return validateProfile(config, definitions)
+		})
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			if problems == nil {
+				problems = []string{}
+			}
+			if err := printJSON(struct {
+				Problems []string `json:"problems"`
+			}{problems}); err != nil {
+				return err
+			}
+		} else if len(problems) == 0 {
+			fmt.Printf("%s is valid.\n", config.path)
+		} else {
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problems found", len(problems))
+		}
+		return nil
+	},
+}
+
+// karabinerModifiers are the modifier names Karabiner accepts in from and to
+// events; "any" is only valid as an optional modifier
+var karabinerModifiers = []string{
+	"caps_lock", "fn",
+	"command", "left_command", "right_command",
+	"control", "left_control", "right_control",
+	"option", "left_option", "right_option",
+	"shift", "left_shift", "right_shift",
+}
+
+// validateProfile returns the problems of a profile's config: unknown key codes
+// and modifiers in the generated rules, clashing
+// layer keys, and the lint findings
+func validateProfile(config *Config, definitions keyDefinitions) ([]string, error) {
+	profile, err := buildProfile(config, "validate", Profile{})
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	report := func(problem string) {
+		if !slices.Contains(problems, problem) {
+			problems = append(problems, problem)
+		}
+	}
+	checkName := func(where, kind, name string) {
+		if name == "" || definitions.contains(kind, name) {
+			return
+		}
+		report(fmt.Sprintf("%s: unknown %s %q (run \"karabingen keys list %s\" to see valid names)", where, kind, name, kind))
+	}
+	checkModifiers := func(where string, modifiers []string, optional bool) {
+		for _, modifier := range modifiers {
+			if !slices.Contains(karabinerModifiers, modifier) && !(optional && modifier == "any") {
+				report(fmt.Sprintf("%s: unknown modifier %q (valid: %s)", where, modifier, strings.Join(karabinerModifiers, ", ")))
+			}
+		}
+	}
+	checkEvents := func(where string, events []To) {
+		for _, event := range events {
+			checkName(where, "key_code", event.KeyCode)
+			checkName(where, "consumer_key_code", event.ConsumerKeyCode)
+			checkModifiers(where, event.Modifiers, false)
+		}
+	}
+
+	for _, modification := range profile.SimpleModifications {
+		where := fmt.Sprintf("simple modification %s", modification.From.KeyCode)
+		checkName(where, "key_code", modification.From.KeyCode)
+		for _, to := range modification.To {
+			checkName(where, "key_code", to.KeyCode)
+		}
+	}
+	for _, rule := range profile.ComplexModifications.Rules {
+		where := fmt.Sprintf("%q", rule.Description)
+		for _, m := range rule.Manipulators {
+			checkName(where, "key_code", m.From.KeyCode)
+			checkName(where, "pointing_button", m.From.PointingButton)
+			for _, key := range m.From.Simultaneous {
+				checkName(where, "key_code", key.KeyCode)
+			}
+			if m.From.Modifiers != nil {
+				checkModifiers(where, m.From.Modifiers.Mandatory, false)
+				checkModifiers(where, m.From.Modifiers.Optional, true)
+			}
+			checkEvents(where, m.To)
+			checkEvents(where, m.ToIfAlone)
+			checkEvents(where, m.ToIfHeldDown)
+			checkEvents(where, m.ToAfterKeyUp)
+			if m.ToDelayedAction != nil {
+				checkEvents(where, m.ToDelayedAction.ToIfInvoked)
+				checkEvents(where, m.ToDelayedAction.ToIfCanceled)
+			}
+		}
+	}
+
+	// Layer keys are pressed with the hyper key held, so a hyper key on the
+	// option modifier turns them into option bindings
+	hyperIsOption := modifierMatches(config.Keybindings.OptionModifier, config.Hyperkey)
+	layerKeys := map[string]bool{}
+	for _, layer := range config.Keybindings.Layers {
+		if layerKeys[layer.Key] {
+			report(fmt.Sprintf("layer %s: defined twice, merge the sub bindings into one layer", layer.Key))
+		}
+		layerKeys[layer.Key] = true
+		if layer.Key == config.Hyperkey {
+			report(fmt.Sprintf("layer %s: the key is the hyper key, pick another layer key", layer.Key))
+		}
+		if _, ok := config.Keybindings.Option[layer.Key]; ok && hyperIsOption {
+			report(fmt.Sprintf("layer %s: option+%s is also an option binding, and the hyper key %s is an option key", layer.Key, layer.Key, config.Hyperkey))
+		}
+	}
+
+	found, err := lintProfile(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, problem := range found {
+		report(problem)
+	}
+	return problems, nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+)
+
+// brightnessScript presses the brightness key (144 up, 145 down) enough times to reach the limit
+const brightnessScript = `osascript -e 'tell application "System Events"' -e 'repeat 16 times' -e 'key code %d' -e 'end repeat' -e 'end tell'`
+
+// createVolumeLayer builds a layer adjusting volume and brightness with consumer keys.
+// Held keys repeat, shifted keys jump to the minimum/maximum.
+func createVolumeLayer(volumeConfig VolumeLayerConfig) LayerConfig {
+	// Option+Shift makes macOS change volume/brightness in quarter steps
+	var modifiers ModifierList
+	if boolValue(volumeConfig.Fine, true) {
+		modifiers = ModifierList{"option", "shift"}
+	}
+
+	return LayerConfig{
+		Key:  volumeConfig.Key,
+		Type: "consumer",
+		Sub: map[string]LayerBinding{
+			"j": {Val: "volume_decrement", Modifiers: modifiers},
+			"k": {Val: "volume_increment", Modifiers: modifiers},
+			"m": {Val: "mute"},
+			"h": {Val: "display_brightness_decrement", Modifiers: modifiers},
+			"l": {Val: "display_brightness_increment", Modifiers: modifiers},
+
+			"shift+j": {Type: "shell", Val: `osascript -e 'set volume output volume 0'`},
+			"shift+k": {Type: "shell", Val: `osascript -e 'set volume output volume 100'`},
+			"shift+h": {Type: "shell", Val: fmt.Sprintf(brightnessScript, 145)},
+			"shift+l": {Type: "shell", Val: fmt.Sprintf(brightnessScript, 144)},
+		},
+	}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+)
+
+// windowManagerCommands holds the command templates of a tiling window manager
+type windowManagerCommands struct {
+	focus      string // %s is the direction
+	swap       string // %s is the direction
+	space      string // %d is the space number
+	moveSpace  string // %d is the space number
+	fullscreen string
+	float      string
+}
+
+var windowManagers = map[string]windowManagerCommands{
+	"yabai": {
+		focus:      "-m window --focus %s",
+		swap:       "-m window --swap %s",
+		space:      "-m space --focus %d",
+		moveSpace:  "-m window --space %d",
+		fullscreen: "-m window --toggle zoom-fullscreen",
+		float:      "-m window --toggle float",
+	},
+	"aerospace": {
+		focus:      "focus %s",
+		swap:       "move %s",
+		space:      "workspace %d",
+		moveSpace:  "move-node-to-workspace %d",
+		fullscreen: "fullscreen",
+		float:      "layout floating tiling",
+	},
+}
+
+// createWindowLayer builds a shell layer driving yabai or AeroSpace
+func createWindowLayer(windowConfig WindowLayerConfig) (LayerConfig, error) {
+	commands, ok := windowManagers[windowConfig.Manager]
+	if !ok {
+		return LayerConfig{}, fmt.Errorf("unsupported window manager: %s (supported: yabai, aerospace)", windowConfig.Manager)
+	}
+
+	binary := windowConfig.Path
+	if binary == "" {
+		binary = findExecutable(windowConfig.Manager)
+	}
+	binary = shellWord(binary)
+
+	// yabai names directions by compass, AeroSpace by screen side
+	directions := map[string]string{"h": "west", "j": "south", "k": "north", "l": "east"}
+	if windowConfig.Manager == "aerospace" {
+		directions = map[string]string{"h": "left", "j": "down", "k": "up", "l": "right"}
+	}
+
+	sub := map[string]LayerBinding{
+		"f": {Val: fmt.Sprintf("%s %s", binary, commands.fullscreen)},
+		"t": {Val: fmt.Sprintf("%s %s", binary, commands.float)},
+	}
+	for key, direction := range directions {
+		sub[key] = LayerBinding{Val: fmt.Sprintf("%s %s", binary, fmt.Sprintf(commands.focus, direction))}
+		sub["shift+"+key] = LayerBinding{Val: fmt.Sprintf("%s %s", binary, fmt.Sprintf(commands.swap, direction))}
+	}
+	for i := 1; i <= 9; i++ {
+		key := fmt.Sprintf("%d", i)
+		sub[key] = LayerBinding{Val: fmt.Sprintf("%s %s", binary, fmt.Sprintf(commands.space, i))}
+		sub["shift+"+key] = LayerBinding{Val: fmt.Sprintf("%s %s", binary, fmt.Sprintf(commands.moveSpace, i))}
+	}
+
+	return LayerConfig{
+		Key:       windowConfig.Key,
+		Type:      "shell",
+		Sub:       sub,
+		Mode:      windowConfig.Mode,
+		TimeoutMs: windowConfig.TimeoutMs,
+	}, nil
+}
+
+// createSpacesLayer builds a layer switching to the Space of a digit key, and
+// with yabai or AeroSpace moving the focused window there on shift+digit.
+// The "keys" backend sends the ctrl+digit shortcuts of Mission Control, which
+// have to be enabled in the keyboard settings and can't move windows.
+func createSpacesLayer(spacesConfig SpacesLayerConfig) (LayerConfig, error) {
+	if spacesConfig.Spaces < 1 || spacesConfig.Spaces > 9 {
+		return LayerConfig{}, fmt.Errorf("spaces_layer.spaces must be between 1 and 9, got %d", spacesConfig.Spaces)
+	}
+
+	if spacesConfig.Backend == "keys" {
+		sub := map[string]LayerBinding{}
+		for i := 1; i <= spacesConfig.Spaces; i++ {
+			sub[fmt.Sprintf("%d", i)] = LayerBinding{Val: fmt.Sprintf("control+%d", i)}
+		}
+		return LayerConfig{Key: spacesConfig.Key, Type: "key", Sub: sub}, nil
+	}
+
+	commands, ok := windowManagers[spacesConfig.Backend]
+	if !ok {
+		return LayerConfig{}, fmt.Errorf("unsupported spaces_layer backend: %s (supported: keys, yabai, aerospace)", spacesConfig.Backend)
+	}
+	binary := spacesConfig.Path
+	if binary == "" {
+		binary = findExecutable(spacesConfig.Backend)
+	}
+	binary = shellWord(binary)
+	sub := map[string]LayerBinding{}
+	for i := 1; i <= spacesConfig.Spaces; i++ {
+		key := fmt.Sprintf("%d", i)
+		sub[key] = LayerBinding{Val: fmt.Sprintf("%s %s", binary, fmt.Sprintf(commands.space, i))}
+		sub["shift+"+key] = LayerBinding{Val: fmt.Sprintf("%s %s", binary, fmt.Sprintf(commands.moveSpace, i))}
+	}
+	return LayerConfig{Key: spacesConfig.Key, Type: "shell", Sub: sub}, nil
+}
+
+// createCycleWindowsRule focuses the next window of the frontmost app, for apps
+// where the built-in cmd+` shortcut does not work. System Events only sees the
+// windows of the current space; yabai also reaches windows on other spaces.
+func createCycleWindowsRule(config *Config, cycle CycleWindowsConfig) (Rule, error) {
+	var command string
+	switch cycle.Manager {
+	case "applescript":
+		// Raising the backmost window walks through all windows in turn
+		command = osascriptCommand(
+			`tell application "System Events" to tell (first process whose frontmost is true)`,
+			`if (count of windows) > 1 then perform action "AXRaise" of last window`,
+			`end tell`,
+		)
+	case "yabai":
+		yabai := shellQuote(config.toolPath("yabai"))
+		query := `[.[] | select(.app == $app and ."is-minimized" == false)] | sort_by(.id) | ` +
+			`(map(."has-focus") | index(true) // -1) as $i | .[($i + 1) % length].id`
+		command = fmt.Sprintf(`app=$(%s -m query --windows --window | %s -r .app) && id=$(%s -m query --windows | %s -r --arg app "$app" %s) && %s -m window --focus "$id"`,
+			yabai, shellQuote(config.toolPath("jq")), yabai, shellQuote(config.toolPath("jq")), shellQuote(query), yabai)
+	default:
+		return Rule{}, fmt.Errorf("unsupported cycle_windows manager: %s (supported: applescript, yabai)", cycle.Manager)
+	}
+
+	from, conditions := createTriggerFrom(cycle.TriggerConfig, false)
+
+	return Rule{
+		Description: fmt.Sprintf("Cycle windows of the frontmost app (%s)", cycle.Manager),
+		Manipulators: []Manipulator{
+			{
+				Type:        "basic",
+				Description: "Focus next window of the frontmost app",
+				From:        from,
+				To:          []To{{ShellCommand: command}},
+				Conditions:  conditions,
+			},
+		},
+	}, nil
+}
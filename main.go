@@ -7,7 +7,11 @@ import (
 	"github.com/fgazat/karabingen/cmd"
 )
 
+// version is set at build time by goreleaser
+var version = "dev"
+
 func main() {
+	cmd.SetVersion(version)
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)